@@ -0,0 +1,224 @@
+package gaws
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// RecorderMode is whether a Recorder records or replays.
+type RecorderMode int
+
+const (
+	ModeRecord RecorderMode = iota // Send requests and save them with their responses.
+	ModeReplay                     // Answer requests from the cassette without sending them.
+)
+
+// recordedHeaders are the request headers kept in a cassette. Anything else, such as Authorization and X-Amz-Security-Token, is scrubbed.
+var recordedHeaders = []string{"Content-Type", "X-Amz-Target"}
+
+// scrubbedQueryParameters are removed from recorded URLs because they carry credentials or signatures.
+var scrubbedQueryParameters = []string{"X-Amz-Credential", "X-Amz-Signature", "X-Amz-Security-Token", "AWSAccessKeyId", "Signature"}
+
+// RecordedRequest is the part of a request that is saved in a cassette and matched on replay.
+type RecordedRequest struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+	Body    string
+}
+
+// RecordedResponse is a response saved in a cassette.
+type RecordedResponse struct {
+	StatusCode int
+	Headers    map[string]string
+	Body       string
+}
+
+// Interaction is a request and the response it got.
+type Interaction struct {
+	Request  RecordedRequest
+	Response RecordedResponse
+}
+
+// Cassette is a list of interactions that can be saved to and loaded from a file.
+type Cassette struct {
+	Interactions []Interaction
+}
+
+// LoadCassette reads a cassette from a JSON file.
+func LoadCassette(path string) (*Cassette, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cassette := &Cassette{}
+	err = json.Unmarshal(b, cassette)
+	return cassette, err
+}
+
+// Save writes the cassette to a JSON file.
+func (c *Cassette) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// Recorder is an http.RoundTripper that records interactions to a cassette or replays them from one.
+// Install it as the Transport of the client given to SetHTTPClient:
+//
+//	recorder, _ := gaws.NewRecorder("fixtures/create_stream.json", gaws.ModeReplay)
+//	gaws.SetHTTPClient(&http.Client{Transport: recorder})
+//	defer recorder.Stop()
+type Recorder struct {
+	Mode      RecorderMode
+	Path      string            // Where Stop saves the cassette when recording.
+	Transport http.RoundTripper // Sends requests when recording. Defaults to http.DefaultTransport.
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// NewRecorder returns a Recorder for the cassette at path. Replaying loads the cassette now; recording starts an empty one.
+func NewRecorder(path string, mode RecorderMode) (*Recorder, error) {
+	r := &Recorder{Mode: mode, Path: path, cassette: &Cassette{}}
+	if mode == ModeReplay {
+		cassette, err := LoadCassette(path)
+		if err != nil {
+			return nil, err
+		}
+		r.cassette = cassette
+		r.used = make([]bool, len(cassette.Interactions))
+	}
+	return r, nil
+}
+
+// Stop saves the cassette if the Recorder is recording.
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Mode != ModeRecord {
+		return nil
+	}
+	return r.cassette.Save(r.Path)
+}
+
+// RoundTrip records or replays one request.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded, err := recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.Mode == ModeReplay {
+		return r.replay(req, recorded)
+	}
+	return r.record(req, recorded)
+}
+
+// recordRequest reads the body of req, puts it back, and returns the scrubbed parts of req to save or match.
+func recordRequest(req *http.Request) (RecordedRequest, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return RecordedRequest{}, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	headers := map[string]string{}
+	for _, name := range recordedHeaders {
+		if value := req.Header.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+
+	return RecordedRequest{Method: req.Method, URL: scrubURL(req.URL), Headers: headers, Body: string(body)}, nil
+}
+
+// scrubURL removes credentials and signatures from a URL.
+func scrubURL(u *url.URL) string {
+	scrubbed := *u
+	scrubbed.User = nil
+	query := scrubbed.Query()
+	for _, name := range scrubbedQueryParameters {
+		query.Del(name)
+	}
+	scrubbed.RawQuery = query.Encode()
+	return scrubbed.String()
+}
+
+func (r *Recorder) record(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	headers := map[string]string{}
+	for name := range resp.Header {
+		headers[name] = resp.Header.Get(name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request:  recorded,
+		Response: RecordedResponse{StatusCode: resp.StatusCode, Headers: headers, Body: string(body)},
+	})
+	return resp, nil
+}
+
+// replay answers with the first unused interaction whose method, URL, X-Amz-Target and body match.
+func (r *Recorder) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || !matches(interaction.Request, recorded) {
+			continue
+		}
+		r.used[i] = true
+
+		resp := &http.Response{
+			StatusCode:    interaction.Response.StatusCode,
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{},
+			Body:          ioutil.NopCloser(strings.NewReader(interaction.Response.Body)),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}
+		for name, value := range interaction.Response.Headers {
+			resp.Header.Set(name, value)
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("gaws: no recorded interaction for %v %v %v", recorded.Method, recorded.URL, recorded.Headers["X-Amz-Target"])
+}
+
+func matches(a RecordedRequest, b RecordedRequest) bool {
+	return a.Method == b.Method && a.URL == b.URL && a.Headers["X-Amz-Target"] == b.Headers["X-Amz-Target"] && a.Body == b.Body
+}
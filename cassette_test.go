@@ -0,0 +1,81 @@
+package gaws
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func testEcho(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	w.Header().Set("X-Amzn-Requestid", "test-request")
+	w.Write([]byte("echo: " + string(body)))
+}
+
+func TestRecorder(t *testing.T) {
+	Convey("Given a request recorded to a cassette", t, func() {
+		dir, _ := ioutil.TempDir("", "gaws")
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "cassette.json")
+
+		ts := httptest.NewServer(http.HandlerFunc(testEcho))
+
+		recorder, err := NewRecorder(path, ModeRecord)
+		So(err, ShouldBeNil)
+		SetHTTPClient(&http.Client{Transport: recorder})
+		defer SetHTTPClient(nil)
+
+		r := canonicalRequest()
+		r.URL = ts.URL + "/?X-Amz-Signature=secret"
+		r.Method = "POST"
+		r.Headers["X-Amz-Target"] = "Test.Echo"
+		r.Headers["Authorization"] = "AWS4-HMAC-SHA256 Credential=secret"
+		r.Body = []byte("hello")
+
+		body, err := r.Do()
+		So(err, ShouldBeNil)
+		So(string(body), ShouldEqual, "echo: hello")
+		So(recorder.Stop(), ShouldBeNil)
+		ts.Close()
+
+		Convey("The cassette has no credentials or signatures in it", func() {
+			saved, _ := ioutil.ReadFile(path)
+			So(strings.Contains(string(saved), "secret"), ShouldBeFalse)
+			So(strings.Contains(string(saved), "Test.Echo"), ShouldBeTrue)
+		})
+		Convey("Replaying returns the recorded response without a server", func() {
+			replayer, err := NewRecorder(path, ModeReplay)
+			So(err, ShouldBeNil)
+			SetHTTPClient(&http.Client{Transport: replayer})
+
+			body, err := r.Do()
+			So(err, ShouldBeNil)
+			So(string(body), ShouldEqual, "echo: hello")
+
+			Convey("And each interaction is only replayed once", func() {
+				_, err := r.Do()
+				So(err, ShouldNotBeNil)
+			})
+		})
+		Convey("Replaying a request with a different body fails", func() {
+			replayer, _ := NewRecorder(path, ModeReplay)
+			SetHTTPClient(&http.Client{Transport: replayer})
+
+			r.Body = []byte("goodbye")
+			_, err := r.Do()
+			So(err, ShouldNotBeNil)
+		})
+	})
+	Convey("Given a cassette file that does not exist", t, func() {
+		_, err := NewRecorder("does-not-exist.json", ModeReplay)
+		Convey("Replaying it returns an error", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,548 @@
+// Command gaws-kinesis runs common Kinesis stream operations from the command line.
+//
+// Usage:
+//
+//	gaws-kinesis [-region us-east-1] [-endpoint URL] [-output table|json] <command> [arguments]
+//
+// The commands are:
+//
+//	create <stream> <shards>                    create a stream
+//	list                                        list streams
+//	describe <stream>                           describe a stream and its shards
+//	put [-key K] <stream> [file ...]            put each line of the files, or of stdin, as a record
+//	tail [-from P] [-at T] <stream>             print records from every open shard as they arrive, following splits and merges
+//	split <stream> <shard> [new starting key]   split a shard, evenly if no hash key is given
+//	merge <stream> <shard> <adjacent shard>     merge two adjacent shards
+//	delete <stream>                             delete a stream
+//	dump [-o FILE] [-format F] <stream>         save records to an archive, see "dump -h"
+//	replay [-format F] [-pace] <stream> [file]  put archived records onto a stream
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/controlgroup/gaws"
+	"github.com/controlgroup/gaws/kinesis"
+)
+
+var errUsage = errors.New("usage: gaws-kinesis [-region R] [-endpoint URL] [-output table|json] create|list|describe|put|tail|split|merge|delete|dump|replay [arguments]")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// command is the state shared by every subcommand.
+type command struct {
+	service *kinesis.KinesisService
+	out     *printer
+	stdin   io.Reader
+}
+
+// run parses the global flags and runs the subcommand named in args.
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("gaws-kinesis", flag.ContinueOnError)
+	region := flags.String("region", gaws.Region, "the AWS region of the stream")
+	endpoint := flags.String("endpoint", "", "the Kinesis endpoint URL. Defaults to the endpoint for -region")
+	output := flags.String("output", "table", "the output format, table or json")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errUsage
+	}
+	if *output != "table" && *output != "json" {
+		return fmt.Errorf("unknown output format %q", *output)
+	}
+	if *endpoint == "" {
+		*endpoint = fmt.Sprintf("https://kinesis.%v.amazonaws.com", *region)
+	}
+
+	c := command{
+		service: &kinesis.KinesisService{Endpoint: *endpoint},
+		out:     &printer{w: stdout, json: *output == "json"},
+		stdin:   stdin,
+	}
+
+	subcommands := map[string]func([]string) error{
+		"create":   c.create,
+		"list":     c.list,
+		"describe": c.describe,
+		"put":      c.put,
+		"tail":     c.tail,
+		"split":    c.split,
+		"merge":    c.merge,
+		"delete":   c.delete,
+		"dump":     c.dump,
+		"replay":   c.replay,
+	}
+
+	name, rest := flags.Arg(0), flags.Args()[1:]
+	subcommand, ok := subcommands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%v", name, errUsage)
+	}
+	return subcommand(rest)
+}
+
+// stream returns the named stream on the command's service.
+func (c *command) stream(name string) *kinesis.Stream {
+	return &kinesis.Stream{Name: name, Service: c.service}
+}
+
+func (c *command) create(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: create <stream> <shards>")
+	}
+	shards, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid shard count %q", args[1])
+	}
+
+	if _, err := c.service.CreateStream(args[0], shards); err != nil {
+		return err
+	}
+	return c.out.message("created", args[0])
+}
+
+func (c *command) list(args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: list")
+	}
+	streams, err := c.service.ListStreams()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, len(streams))
+	for i, stream := range streams {
+		names[i] = stream.Name
+	}
+	if c.out.json {
+		return c.out.value(names)
+	}
+
+	rows := [][]string{}
+	for _, name := range names {
+		rows = append(rows, []string{name})
+	}
+	return c.out.table([]string{"STREAM"}, rows)
+}
+
+func (c *command) describe(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: describe <stream>")
+	}
+	stream := c.stream(args[0])
+
+	description, err := stream.Describe()
+	if err != nil {
+		return err
+	}
+	description.Shards, err = stream.Shards()
+	if err != nil {
+		return err
+	}
+	description.HasMoreShards = false
+
+	if c.out.json {
+		return c.out.value(description)
+	}
+
+	fmt.Fprintf(c.out.w, "%v %v %v\n\n", description.StreamName, description.StreamStatus, description.StreamARN)
+	rows := [][]string{}
+	for _, shard := range description.Shards {
+		state := "OPEN"
+		if !shard.IsOpen() {
+			state = "CLOSED"
+		}
+		rows = append(rows, []string{shard.ShardId, state, shard.HashKeyRange.StartingHashKey, shard.HashKeyRange.EndingHashKey, shard.ParentShardId})
+	}
+	return c.out.table([]string{"SHARD", "STATE", "STARTING HASH KEY", "ENDING HASH KEY", "PARENT"}, rows)
+}
+
+func (c *command) put(args []string) error {
+	flags := flag.NewFlagSet("put", flag.ContinueOnError)
+	key := flags.String("key", "", "the partition key for every record. Defaults to a random key per record")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 {
+		return errors.New("usage: put [-key K] <stream> [file ...]")
+	}
+	stream := c.stream(flags.Arg(0))
+
+	inputs := []io.Reader{}
+	for _, path := range flags.Args()[1:] {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		inputs = append(inputs, f)
+	}
+	if len(inputs) == 0 {
+		inputs = append(inputs, c.stdin)
+	}
+
+	w := &kinesis.Writer{Stream: stream, Keyer: kinesis.RandomKeyer{}}
+	if *key != "" {
+		w.Keyer = kinesis.FixedKeyer{Key: *key}
+	}
+
+	// Lines too long for a record are dropped by the Writer and counted as failed.
+	lines, failed := 0, 0
+	last := byte('\n')
+	buf := make([]byte, 64*1024)
+	r := io.MultiReader(inputs...)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			lines += bytes.Count(buf[:n], []byte{'\n'})
+			last = buf[n-1]
+			if _, err := w.Write(buf[:n]); err == kinesis.ErrFrameTooLarge {
+				failed++
+			} else if err != nil && err != kinesis.ErrPutRecordsFailed {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if last != '\n' {
+		lines++
+	}
+	for {
+		err := w.Close()
+		if err == kinesis.ErrFrameTooLarge {
+			failed++
+			continue
+		}
+		if err == kinesis.ErrPutRecordsFailed {
+			failed += w.Unsent()
+		} else if err != nil {
+			return err
+		}
+		break
+	}
+	put := lines - failed
+
+	var err error
+	if c.out.json {
+		err = c.out.value(map[string]int{"Put": put, "Failed": failed})
+	} else {
+		_, err = fmt.Fprintf(c.out.w, "put %v records, %v failed\n", put, failed)
+	}
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%v records failed", failed)
+	}
+	return nil
+}
+
+// parsePosition turns the -from and -at flags of tail into a StartPosition.
+func parsePosition(from string, at string) (kinesis.StartPosition, error) {
+	switch strings.ToLower(from) {
+	case "latest":
+		return kinesis.Latest(), nil
+	case "trim_horizon", "trim-horizon":
+		return kinesis.TrimHorizon(), nil
+	case "at_timestamp", "at-timestamp":
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return kinesis.StartPosition{}, fmt.Errorf("-at must be an RFC 3339 time: %v", err)
+		}
+		return kinesis.AtTimestamp(t), nil
+	}
+	return kinesis.StartPosition{}, fmt.Errorf("unknown position %q, use latest, trim_horizon or at_timestamp", from)
+}
+
+// tailedRecord is a record printed by tail.
+type tailedRecord struct {
+	ShardId        string
+	SequenceNumber string
+	PartitionKey   string
+	ArrivalTime    time.Time
+	Data           string
+}
+
+func (c *command) tail(args []string) error {
+	flags := flag.NewFlagSet("tail", flag.ContinueOnError)
+	from := flags.String("from", "latest", "where to start reading: latest, trim_horizon or at_timestamp")
+	at := flags.String("at", "", "the RFC 3339 time to start at with -from at_timestamp")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: tail [-from latest|trim_horizon|at_timestamp] [-at TIME] <stream>")
+	}
+
+	position, err := parsePosition(*from, *at)
+	if err != nil {
+		return err
+	}
+
+	records := make(chan tailedRecord)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- followShards(c.stream(flags.Arg(0)), position, records)
+	}()
+
+	for {
+		select {
+		case record := <-records:
+			if c.out.json {
+				if err := c.out.value(record); err != nil {
+					return err
+				}
+				continue
+			}
+			fmt.Fprintf(c.out.w, "%v\t%v\t%v\t%v\n", record.ShardId, record.SequenceNumber, record.PartitionKey, record.Data)
+		case err := <-errc:
+			return err
+		}
+	}
+}
+
+// followShards reads every open shard of stream from position and sends their records to records. When a shard is
+// closed by a split or merge, it goes on with the shard's children from their start, once all of a child's parents
+// have been read to their end. It only returns with an error.
+func followShards(stream *kinesis.Stream, position kinesis.StartPosition, records chan<- tailedRecord) error {
+	shards, err := stream.OpenShards()
+	if err != nil {
+		return err
+	}
+
+	closed := make(chan string)
+	errc := make(chan error, 1)
+	started := map[string]bool{}
+	finished := map[string]bool{}
+	start := func(shard kinesis.Shard, position kinesis.StartPosition) {
+		started[shard.ShardId] = true
+		go tailShard(&shard, position, records, closed, errc)
+	}
+	for _, shard := range shards {
+		start(shard, position)
+	}
+
+	for {
+		select {
+		case shardId := <-closed:
+			finished[shardId] = true
+			shards, err := stream.Shards()
+			if err != nil {
+				return err
+			}
+			for _, shard := range shards {
+				if !started[shard.ShardId] && parentsFinished(shard, started, finished) {
+					start(shard, kinesis.TrimHorizon())
+				}
+			}
+		case err := <-errc:
+			return err
+		}
+	}
+}
+
+// parentsFinished reports whether shard is the child of a shard that was followed, and every parent that was followed has been read to its end.
+func parentsFinished(shard kinesis.Shard, started map[string]bool, finished map[string]bool) bool {
+	child := false
+	for _, parent := range []string{shard.ParentShardId, shard.AdjacentParentShardId} {
+		if parent == "" || !started[parent] {
+			continue
+		}
+		if !finished[parent] {
+			return false
+		}
+		child = true
+	}
+	return child
+}
+
+// tailShard sends the records of shard to records, then sends its ID to closed once it has been read to its end.
+func tailShard(shard *kinesis.Shard, position kinesis.StartPosition, records chan<- tailedRecord, closed chan<- string, errc chan<- error) {
+	shardRecords, shardErrc := shard.StreamRecords(position)
+	for {
+		select {
+		case record, ok := <-shardRecords:
+			if !ok {
+				closed <- shard.ShardId
+				return
+			}
+			data, err := record.Bytes()
+			if err != nil {
+				errc <- err
+				return
+			}
+			records <- tailedRecord{ShardId: shard.ShardId, SequenceNumber: record.SequenceNumber, PartitionKey: record.PartitionKey, ArrivalTime: record.ArrivalTime(), Data: string(data)}
+		case err := <-shardErrc:
+			errc <- err
+			return
+		}
+	}
+}
+
+func (c *command) split(args []string) error {
+	if len(args) != 2 && len(args) != 3 {
+		return errors.New("usage: split <stream> <shard> [new starting hash key]")
+	}
+	stream := c.stream(args[0])
+
+	if len(args) == 3 {
+		if err := stream.SplitShard(args[1], args[2]); err != nil {
+			return err
+		}
+		return c.out.message("splitting", args[1])
+	}
+
+	shards, err := stream.Shards()
+	if err != nil {
+		return err
+	}
+	for _, shard := range shards {
+		if shard.ShardId == args[1] {
+			if err := shard.SplitEvenly(); err != nil {
+				return err
+			}
+			return c.out.message("splitting", args[1])
+		}
+	}
+	return fmt.Errorf("stream %v has no shard %v", args[0], args[1])
+}
+
+func (c *command) merge(args []string) error {
+	if len(args) != 3 {
+		return errors.New("usage: merge <stream> <shard> <adjacent shard>")
+	}
+	if err := c.stream(args[0]).MergeShards(args[1], args[2]); err != nil {
+		return err
+	}
+	return c.out.message("merging", args[1]+" "+args[2])
+}
+
+func (c *command) delete(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: delete <stream>")
+	}
+	if err := c.stream(args[0]).Delete(); err != nil {
+		return err
+	}
+	return c.out.message("deleting", args[0])
+}
+
+func (c *command) dump(args []string) error {
+	flags := flag.NewFlagSet("dump", flag.ContinueOnError)
+	output := flags.String("o", "", "the archive file to write. Defaults to stdout")
+	format := flags.String("format", "json", "the archive format, json or binary")
+	from := flags.String("from", "trim_horizon", "where to start reading: latest, trim_horizon or at_timestamp")
+	at := flags.String("at", "", "the RFC 3339 time to start at with -from at_timestamp")
+	until := flags.String("until", "", "the RFC 3339 time to stop at. Defaults to the tip of each shard")
+	max := flags.Int("max", 0, "the most records to dump. 0 means no limit")
+	shards := flags.String("shards", "", "a comma separated list of shard IDs to dump. Defaults to every shard")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: dump [-o FILE] [-format json|binary] [-from P] [-at T] [-until T] [-max N] [-shards IDS] <stream>")
+	}
+
+	position, err := parsePosition(*from, *at)
+	if err != nil {
+		return err
+	}
+	options := kinesis.DumpOptions{Position: position, MaxRecords: *max}
+	if *until != "" {
+		options.Until, err = time.Parse(time.RFC3339, *until)
+		if err != nil {
+			return fmt.Errorf("-until must be an RFC 3339 time: %v", err)
+		}
+	}
+	if *shards != "" {
+		options.ShardIds = strings.Split(*shards, ",")
+	}
+
+	w := c.out.w
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	var archive kinesis.ArchiveWriter
+	switch *format {
+	case "json":
+		archive = kinesis.NewJSONArchiveWriter(w)
+	case "binary":
+		archive = kinesis.NewBinaryArchiveWriter(w)
+	default:
+		return fmt.Errorf("unknown archive format %q", *format)
+	}
+
+	n, err := c.stream(flags.Arg(0)).Dump(archive, options)
+	if err != nil {
+		return err
+	}
+	if *output != "" {
+		return c.out.message(fmt.Sprintf("dumped %v records to", n), *output)
+	}
+	return nil
+}
+
+func (c *command) replay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	format := flags.String("format", "json", "the archive format, json or binary")
+	pace := flags.Bool("pace", false, "wait between records as long as passed between their original arrivals")
+	speed := flags.Float64("speed", 1, "how many times faster than the original to replay with -pace")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 && flags.NArg() != 2 {
+		return errors.New("usage: replay [-format json|binary] [-pace] [-speed X] <stream> [file]")
+	}
+
+	r := c.stdin
+	if flags.NArg() == 2 {
+		f, err := os.Open(flags.Arg(1))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var archive kinesis.ArchiveReader
+	switch *format {
+	case "json":
+		archive = kinesis.NewJSONArchiveReader(r)
+	case "binary":
+		archive = kinesis.NewBinaryArchiveReader(r)
+	default:
+		return fmt.Errorf("unknown archive format %q", *format)
+	}
+
+	n, err := c.stream(flags.Arg(0)).Replay(archive, kinesis.ReplayOptions{Pace: *pace, Speed: *speed})
+	if err != nil {
+		return err
+	}
+	return c.out.message(fmt.Sprintf("replayed %v records to", n), flags.Arg(0))
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/controlgroup/gaws/kinesis"
+	"github.com/controlgroup/gaws/kinesis/kinesistest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func testListStreams(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(`{"HasMoreStreams": false, "StreamNames": ["foo", "bar"]}`))
+}
+
+func TestList(t *testing.T) {
+	Convey("Given a server that lists two streams", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(testListStreams))
+		out := &bytes.Buffer{}
+
+		Convey("list prints a table of them", func() {
+			err := run([]string{"-endpoint", ts.URL, "list"}, nil, out)
+			So(err, ShouldBeNil)
+			So(out.String(), ShouldEqual, "STREAM\nfoo\nbar\n")
+		})
+		Convey("list -output json prints a JSON array", func() {
+			err := run([]string{"-endpoint", ts.URL, "-output", "json", "list"}, nil, out)
+			So(err, ShouldBeNil)
+			So(out.String(), ShouldEqual, "[\"foo\",\"bar\"]\n")
+		})
+	})
+}
+
+func TestPut(t *testing.T) {
+	Convey("Given a server that accepts every record", t, func() {
+		var sent struct {
+			Records []struct {
+				Data         string
+				PartitionKey string
+			}
+		}
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &sent)
+			w.Write([]byte(`{"FailedRecordCount": 0}`))
+		}))
+		out := &bytes.Buffer{}
+
+		Convey("put sends each line of stdin as a record", func() {
+			err := run([]string{"-endpoint", ts.URL, "put", "-key", "k", "foo"}, strings.NewReader("one\ntwo\n"), out)
+			So(err, ShouldBeNil)
+			So(len(sent.Records), ShouldEqual, 2)
+			So(sent.Records[1].PartitionKey, ShouldEqual, "k")
+			So(out.String(), ShouldEqual, "put 2 records, 0 failed\n")
+		})
+		Convey("put sends lines longer than 64 KiB", func() {
+			input := strings.Repeat("x", 100<<10) + "\nok"
+			So(run([]string{"-endpoint", ts.URL, "put", "foo"}, strings.NewReader(input), out), ShouldBeNil)
+			So(len(sent.Records), ShouldEqual, 2)
+			So(out.String(), ShouldEqual, "put 2 records, 0 failed\n")
+		})
+		Convey("put fails a line longer than a record but sends the others", func() {
+			input := strings.Repeat("x", kinesis.MaxRecordBytes+1) + "\nok\n"
+			So(run([]string{"-endpoint", ts.URL, "put", "foo"}, strings.NewReader(input), out), ShouldNotBeNil)
+			So(len(sent.Records), ShouldEqual, 1)
+			So(out.String(), ShouldEqual, "put 1 records, 1 failed\n")
+		})
+	})
+}
+
+func TestReplay(t *testing.T) {
+	Convey("Given a server that accepts every record", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"FailedRecordCount": 0}`))
+		}))
+		out := &bytes.Buffer{}
+
+		Convey("replay puts the records of an archive read from stdin", func() {
+			archive := `{"PartitionKey": "a", "Data": "b25l"}` + "\n" + `{"PartitionKey": "b", "Data": "dHdv"}` + "\n"
+			err := run([]string{"-endpoint", ts.URL, "replay", "foo"}, strings.NewReader(archive), out)
+			So(err, ShouldBeNil)
+			So(out.String(), ShouldEqual, "replayed 2 records to foo\n")
+		})
+		Convey("replay rejects unknown archive formats", func() {
+			err := run([]string{"-endpoint", ts.URL, "replay", "-format", "xml", "foo"}, strings.NewReader(""), out)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+// nextTailed returns the next record from records, or fails the test if none comes within a second.
+func nextTailed(records <-chan tailedRecord) tailedRecord {
+	select {
+	case record := <-records:
+		return record
+	case <-time.After(time.Second):
+		So("no record", ShouldBeNil)
+		return tailedRecord{}
+	}
+}
+
+func TestFollowShards(t *testing.T) {
+	Convey("Given a stream with one shard being followed", t, func() {
+		server := kinesistest.NewServer()
+		defer server.Close()
+		ks := kinesis.KinesisService{Endpoint: server.URL}
+		stream, _ := ks.CreateStream("foo", 1)
+		stream.PutRecord("a", []byte("one"))
+
+		records := make(chan tailedRecord)
+		go followShards(&stream, kinesis.TrimHorizon(), records)
+		So(nextTailed(records).Data, ShouldEqual, "one")
+
+		Convey("After a split, records put on the children are followed", func() {
+			shards, _ := stream.OpenShards()
+			So(shards[0].SplitEvenly(), ShouldBeNil)
+			stream.PutRecord("a", []byte("two"))
+
+			record := nextTailed(records)
+			So(record.Data, ShouldEqual, "two")
+			So(record.ShardId, ShouldNotEqual, shards[0].ShardId)
+
+			Convey("And after the children are merged, records put on their child are followed", func() {
+				children, _ := stream.OpenShards()
+				So(stream.MergeShards(children[0].ShardId, children[1].ShardId), ShouldBeNil)
+				stream.PutRecord("a", []byte("three"))
+				So(nextTailed(records).Data, ShouldEqual, "three")
+			})
+		})
+	})
+}
+
+func TestRunErrors(t *testing.T) {
+	Convey("Running without a command returns the usage", t, func() {
+		So(run([]string{}, nil, &bytes.Buffer{}), ShouldEqual, errUsage)
+	})
+	Convey("Running an unknown command returns an error", t, func() {
+		So(run([]string{"frobnicate"}, nil, &bytes.Buffer{}), ShouldNotBeNil)
+	})
+	Convey("Running with an unknown output format returns an error", t, func() {
+		So(run([]string{"-output", "xml", "list"}, nil, &bytes.Buffer{}), ShouldNotBeNil)
+	})
+	Convey("create with a shard count that is not a number returns an error", t, func() {
+		So(run([]string{"create", "foo", "many"}, nil, &bytes.Buffer{}), ShouldNotBeNil)
+	})
+}
+
+func TestParsePosition(t *testing.T) {
+	Convey("latest and trim_horizon need no time", t, func() {
+		p, err := parsePosition("latest", "")
+		So(err, ShouldBeNil)
+		So(p.String(), ShouldEqual, "LATEST")
+
+		p, err = parsePosition("trim_horizon", "")
+		So(err, ShouldBeNil)
+		So(p.String(), ShouldEqual, "TRIM_HORIZON")
+	})
+	Convey("at_timestamp needs an RFC 3339 time", t, func() {
+		p, err := parsePosition("at_timestamp", time.Now().Format(time.RFC3339))
+		So(err, ShouldBeNil)
+		So(p.String(), ShouldEqual, "AT_TIMESTAMP")
+
+		_, err = parsePosition("at_timestamp", "yesterday")
+		So(err, ShouldNotBeNil)
+	})
+	Convey("Unknown positions return an error", t, func() {
+		_, err := parsePosition("middle", "")
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// printer writes command results as aligned tables or as one JSON document per line.
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+// value writes v as a line of JSON.
+func (p *printer) value(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(p.w, "%s\n", b)
+	return err
+}
+
+// message reports that an action was taken on a subject.
+func (p *printer) message(action string, subject string) error {
+	if p.json {
+		return p.value(map[string]string{"Action": action, "Subject": subject})
+	}
+	_, err := fmt.Fprintf(p.w, "%v %v\n", action, subject)
+	return err
+}
+
+// table writes rows under header with aligned columns.
+func (p *printer) table(header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,145 @@
+package gaws
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// formatFloat formats a value the way the Prometheus text format expects.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels formats labels, and an extra label if name is not empty, as {name="value",...}.
+func formatLabels(labels Labels, extraName string, extraValue string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := []string{}
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf(`%v="%v"`, name, labelValueEscaper.Replace(labels[name])))
+	}
+	if extraName != "" {
+		parts = append(parts, fmt.Sprintf(`%v="%v"`, extraName, extraValue))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// sortedNames returns the metric names in the registry in order. Callers hold r.mu.
+func (r *Registry) sortedNames() []string {
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedSeries returns the series of a family ordered by their labels.
+func (f *family) sortedSeries() []*series {
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]*series, len(keys))
+	for i, key := range keys {
+		result[i] = f.series[key]
+	}
+	return result
+}
+
+// WritePrometheus writes every metric in the Prometheus text exposition format, so it can be served by any HTTP
+// handler or written to a file for the node exporter's textfile collector.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := bufio.NewWriter(w)
+	kinds := map[metricKind]string{counterKind: "counter", gaugeKind: "gauge", histogramKind: "histogram"}
+
+	for _, name := range r.sortedNames() {
+		f := r.families[name]
+		fmt.Fprintf(b, "# TYPE %v %v\n", name, kinds[f.kind])
+
+		for _, s := range f.sortedSeries() {
+			if f.kind != histogramKind {
+				fmt.Fprintf(b, "%v%v %v\n", name, formatLabels(s.labels, "", ""), formatFloat(s.value))
+				continue
+			}
+
+			var cumulative uint64
+			for i, bound := range s.bounds {
+				cumulative += s.counts[i]
+				fmt.Fprintf(b, "%v_bucket%v %v\n", name, formatLabels(s.labels, "le", formatFloat(bound)), cumulative)
+			}
+			fmt.Fprintf(b, "%v_bucket%v %v\n", name, formatLabels(s.labels, "le", "+Inf"), s.count)
+			fmt.Fprintf(b, "%v_sum%v %v\n", name, formatLabels(s.labels, "", ""), formatFloat(s.sum))
+			fmt.Fprintf(b, "%v_count%v %v\n", name, formatLabels(s.labels, "", ""), s.count)
+		}
+	}
+	return b.Flush()
+}
+
+// expvarHistogram is how a histogram appears in Expvar.
+type expvarHistogram struct {
+	Count   uint64
+	Sum     float64
+	Buckets map[string]uint64 // Cumulative counts by upper bound.
+}
+
+// snapshot returns every metric as a map from name to a map from formatted labels to value.
+func (r *Registry) snapshot() interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := map[string]map[string]interface{}{}
+	for name, f := range r.families {
+		values := map[string]interface{}{}
+		for _, s := range f.series {
+			labels := formatLabels(s.labels, "", "")
+			if f.kind != histogramKind {
+				values[labels] = s.value
+				continue
+			}
+
+			h := expvarHistogram{Count: s.count, Sum: s.sum, Buckets: map[string]uint64{}}
+			var cumulative uint64
+			for i, bound := range s.bounds {
+				cumulative += s.counts[i]
+				h.Buckets[formatFloat(bound)] = cumulative
+			}
+			values[labels] = h
+		}
+		result[name] = values
+	}
+	return result
+}
+
+// Expvar returns an expvar.Var that reports every metric as JSON. Publish it with expvar.Publish to include it in /debug/vars.
+func (r *Registry) Expvar() expvar.Var {
+	return expvar.Func(r.snapshot)
+}
@@ -0,0 +1,243 @@
+package firehose
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/controlgroup/gaws"
+)
+
+// Firehose limits on PutRecordBatch.
+const (
+	MaxPutRecordBatchRecords = 500     // The most records one PutRecordBatch call accepts.
+	MaxPutRecordBatchBytes   = 4 << 20 // The most data one PutRecordBatch call accepts.
+)
+
+// ErrPutRecordBatchFailed is returned when some records could not be put after gaws.MaxTries attempts.
+var ErrPutRecordBatchFailed = errors.New("firehose: some records could not be put")
+
+// S3DestinationDescription describes an S3 destination of a delivery stream.
+type S3DestinationDescription struct {
+	BucketARN         string
+	BufferingHints    BufferingHints
+	CompressionFormat string
+	ErrorOutputPrefix string
+	Prefix            string
+	RoleARN           string
+}
+
+// DestinationDescription describes one destination of a delivery stream.
+type DestinationDescription struct {
+	DestinationId            string
+	S3DestinationDescription *S3DestinationDescription
+}
+
+// DeliveryStreamDescription is the description of a delivery stream.
+type DeliveryStreamDescription struct {
+	CreateTimestamp      float64 // When the delivery stream was created, in seconds since the epoch.
+	DeliveryStreamARN    string
+	DeliveryStreamName   string
+	DeliveryStreamStatus string // The status of the delivery stream. May be CREATING, DELETING or ACTIVE.
+	DeliveryStreamType   string // DirectPut or KinesisStreamAsSource.
+	Destinations         []DestinationDescription
+	HasMoreDestinations  bool
+	LastUpdateTimestamp  float64
+	VersionId            string
+}
+
+type describeDeliveryStreamRequest struct {
+	DeliveryStreamName string
+}
+
+type describeDeliveryStreamResult struct {
+	DeliveryStreamDescription DeliveryStreamDescription
+}
+
+// Describe describes a delivery stream. It is calling the DescribeDeliveryStream API call.
+// See http://docs.aws.amazon.com/firehose/latest/APIReference/API_DescribeDeliveryStream.html for more details.
+func (d *DeliveryStream) Describe() (DeliveryStreamDescription, error) {
+	result := describeDeliveryStreamResult{}
+
+	body := describeDeliveryStreamRequest{DeliveryStreamName: d.Name}
+	bodyAsJson, err := json.Marshal(body)
+
+	req := d.Service.request()
+	req.Body = bodyAsJson
+	req.Headers["X-Amz-Target"] = "Firehose_20150804.DescribeDeliveryStream"
+
+	resp, err := req.Do()
+	if err != nil {
+		return DeliveryStreamDescription{}, err
+	}
+
+	err = json.Unmarshal(resp, &result)
+	return result.DeliveryStreamDescription, err
+}
+
+type deleteDeliveryStreamRequest struct {
+	DeliveryStreamName string
+}
+
+// Delete deletes a delivery stream. Records that have been put but not yet delivered are lost.
+// See http://docs.aws.amazon.com/firehose/latest/APIReference/API_DeleteDeliveryStream.html for more details.
+func (d *DeliveryStream) Delete() error {
+	body := deleteDeliveryStreamRequest{DeliveryStreamName: d.Name}
+	bodyAsJson, err := json.Marshal(body)
+
+	req := d.Service.request()
+	req.Body = bodyAsJson
+	req.Headers["X-Amz-Target"] = "Firehose_20150804.DeleteDeliveryStream"
+
+	_, err = req.Do()
+
+	return err
+}
+
+// record is a Firehose record. Data is Base64 encoded.
+type record struct {
+	Data string
+}
+
+type putRecordRequest struct {
+	DeliveryStreamName string
+	Record             record
+}
+
+type putRecordResult struct {
+	RecordId string
+}
+
+// PutRecord puts data on a delivery stream. It returns the ID Firehose gave the record and an error if it fails.
+// Firehose does not add newlines, so records that should be separate lines in S3 must end with one.
+// See http://docs.aws.amazon.com/firehose/latest/APIReference/API_PutRecord.html for more details.
+func (d *DeliveryStream) PutRecord(data []byte) (string, error) {
+	result := putRecordResult{}
+
+	body := putRecordRequest{DeliveryStreamName: d.Name, Record: record{Data: base64.StdEncoding.EncodeToString(data)}}
+	bodyAsJson, err := json.Marshal(body)
+
+	req := d.Service.request()
+	req.Body = bodyAsJson
+	req.Headers["X-Amz-Target"] = "Firehose_20150804.PutRecord"
+
+	resp, err := req.Do()
+	if err != nil {
+		return "", err
+	}
+
+	err = json.Unmarshal(resp, &result)
+	return result.RecordId, err
+}
+
+type putRecordBatchRequest struct {
+	DeliveryStreamName string
+	Records            []record
+}
+
+// PutRecordBatchResponseEntry is the result of putting one record with PutRecordBatch. ErrorCode is set if that record failed.
+type PutRecordBatchResponseEntry struct {
+	ErrorCode    string
+	ErrorMessage string
+	RecordId     string
+}
+
+// PutRecordBatchOutput is returned by PutRecordBatch. RequestResponses is in the same order as the records that were put.
+type PutRecordBatchOutput struct {
+	FailedPutCount   int
+	RequestResponses []PutRecordBatchResponseEntry
+}
+
+// putRecordBatch makes one PutRecordBatch call.
+func (d *DeliveryStream) putRecordBatch(records [][]byte) (PutRecordBatchOutput, error) {
+	result := PutRecordBatchOutput{}
+
+	body := putRecordBatchRequest{DeliveryStreamName: d.Name, Records: make([]record, len(records))}
+	for i, data := range records {
+		body.Records[i] = record{Data: base64.StdEncoding.EncodeToString(data)}
+	}
+	bodyAsJson, err := json.Marshal(body)
+
+	req := d.Service.request()
+	req.Body = bodyAsJson
+	req.Headers["X-Amz-Target"] = "Firehose_20150804.PutRecordBatch"
+
+	resp, err := req.Do()
+	if err != nil {
+		return PutRecordBatchOutput{}, err
+	}
+
+	err = json.Unmarshal(resp, &result)
+	return result, err
+}
+
+// PutRecordBatch puts records on a delivery stream, split into as many PutRecordBatch calls as the batch limits need.
+// Records that fail are retried with an exponential backoff, up to gaws.MaxTries attempts. The output has the final
+// result of every record, in order, and the error is ErrPutRecordBatchFailed if any record was still failing.
+// See http://docs.aws.amazon.com/firehose/latest/APIReference/API_PutRecordBatch.html for more details.
+func (d *DeliveryStream) PutRecordBatch(records [][]byte) (PutRecordBatchOutput, error) {
+	output := PutRecordBatchOutput{RequestResponses: make([]PutRecordBatchResponseEntry, len(records))}
+
+	for start := 0; start < len(records); {
+		end, size := start, 0
+		for end < len(records) && end-start < MaxPutRecordBatchRecords && (end == start || size+len(records[end]) <= MaxPutRecordBatchBytes) {
+			size += len(records[end])
+			end++
+		}
+
+		if err := d.putWithRetries(records, start, end, output.RequestResponses); err != nil {
+			return output, err
+		}
+		start = end
+	}
+
+	for _, response := range output.RequestResponses {
+		if response.ErrorCode != "" {
+			output.FailedPutCount++
+		}
+	}
+	if output.FailedPutCount > 0 {
+		return output, ErrPutRecordBatchFailed
+	}
+	return output, nil
+}
+
+// putWithRetries puts records[start:end], retrying the ones that fail, and writes each record's final result into responses.
+func (d *DeliveryStream) putWithRetries(records [][]byte, start int, end int, responses []PutRecordBatchResponseEntry) error {
+	pending := []int{}
+	for i := start; i < end; i++ {
+		pending = append(pending, i)
+	}
+
+	for try := 1; len(pending) > 0; try++ {
+		batch := make([][]byte, len(pending))
+		for i, index := range pending {
+			batch[i] = records[index]
+		}
+
+		result, err := d.putRecordBatch(batch)
+		if err != nil {
+			return err
+		}
+
+		failed := []int{}
+		for i, response := range result.RequestResponses {
+			if i >= len(pending) {
+				break
+			}
+			responses[pending[i]] = response
+			if response.ErrorCode != "" {
+				failed = append(failed, pending[i])
+			}
+		}
+		pending = failed
+
+		if len(pending) == 0 || try >= gaws.MaxTries {
+			return nil
+		}
+		time.Sleep(time.Duration(100*math.Pow(2.0, float64(try))) * time.Millisecond)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+// Package firehose provides a way to interact with the AWS Kinesis Firehose service.
+package firehose
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/controlgroup/gaws"
+)
+
+// firehoseError is the error document returned from the Firehose service.
+type firehoseError struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+// Error formats the firehoseError into an error message.
+func (e firehoseError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Type, e.Message)
+}
+
+func firehoseRetryPredicate(status int, body []byte) (bool, error) {
+	if status < 400 {
+		return false, nil
+	}
+
+	// The request failed, but why?
+	error := firehoseError{}
+
+	err := json.Unmarshal(body, &error)
+	if err != nil {
+		return false, err
+	}
+
+	// retry if it is an AWS error
+	if status >= 500 {
+		return true, error
+	}
+
+	if error.Type == "ThrottlingException" {
+		return true, error
+	}
+
+	if error.Type == "ServiceUnavailableException" {
+		return true, error
+	}
+
+	return false, error
+}
+
+func (s *FirehoseService) request() gaws.AWSRequest {
+	r := gaws.AWSRequest{
+		RetryPredicate: firehoseRetryPredicate,
+		Method:         "POST",
+		URL:            s.Endpoint,
+		Headers: map[string]string{
+			"Content-Type": "application/x-amz-json-1.1",
+		},
+	}
+	return r
+}
+
+// FirehoseService is the Kinesis Firehose service at AWS.
+type FirehoseService struct {
+	Endpoint string // Such as https://firehose.us-east-1.amazonaws.com.
+}
+
+// DeliveryStream is a Firehose delivery stream.
+type DeliveryStream struct {
+	Name    string           // The name of the delivery stream
+	Service *FirehoseService // The service for this region
+}
+
+// BufferingHints tell Firehose how much data to buffer before delivering it. Firehose delivers when either is reached.
+type BufferingHints struct {
+	IntervalInSeconds int `json:",omitempty"`
+	SizeInMBs         int `json:",omitempty"`
+}
+
+// S3DestinationConfiguration describes an S3 bucket that a delivery stream delivers to.
+type S3DestinationConfiguration struct {
+	BucketARN         string
+	BufferingHints    *BufferingHints `json:",omitempty"`
+	CompressionFormat string          `json:",omitempty"` // UNCOMPRESSED, GZIP, ZIP or Snappy. Defaults to UNCOMPRESSED.
+	ErrorOutputPrefix string          `json:",omitempty"`
+	Prefix            string          `json:",omitempty"`
+	RoleARN           string          // The IAM role Firehose assumes to write to the bucket.
+}
+
+// KinesisStreamSourceConfiguration describes a Kinesis stream that a delivery stream reads from.
+type KinesisStreamSourceConfiguration struct {
+	KinesisStreamARN string
+	RoleARN          string // The IAM role Firehose assumes to read from the stream.
+}
+
+// DeliveryStreamConfiguration is the configuration of a new delivery stream.
+// Without a KinesisStreamSourceConfiguration, records are put on the delivery stream directly.
+type DeliveryStreamConfiguration struct {
+	S3DestinationConfiguration       *S3DestinationConfiguration
+	KinesisStreamSourceConfiguration *KinesisStreamSourceConfiguration
+}
+
+// createDeliveryStreamRequest is the request to the CreateDeliveryStream API call.
+type createDeliveryStreamRequest struct {
+	DeliveryStreamName               string
+	DeliveryStreamType               string
+	KinesisStreamSourceConfiguration *KinesisStreamSourceConfiguration `json:",omitempty"`
+	S3DestinationConfiguration       *S3DestinationConfiguration       `json:",omitempty"`
+}
+
+// CreateDeliveryStream creates a new delivery stream. It returns a DeliveryStream and an error if it fails.
+// The delivery stream is CREATING until DescribeDeliveryStream reports it ACTIVE.
+// See http://docs.aws.amazon.com/firehose/latest/APIReference/API_CreateDeliveryStream.html for more details.
+func (s *FirehoseService) CreateDeliveryStream(name string, config DeliveryStreamConfiguration) (DeliveryStream, error) {
+
+	deliveryStream := DeliveryStream{Name: name, Service: s}
+
+	body := createDeliveryStreamRequest{
+		DeliveryStreamName:               name,
+		DeliveryStreamType:               "DirectPut",
+		KinesisStreamSourceConfiguration: config.KinesisStreamSourceConfiguration,
+		S3DestinationConfiguration:       config.S3DestinationConfiguration,
+	}
+	if config.KinesisStreamSourceConfiguration != nil {
+		body.DeliveryStreamType = "KinesisStreamAsSource"
+	}
+	bodyAsJson, err := json.Marshal(body)
+
+	req := s.request()
+	req.Body = bodyAsJson
+	req.Headers["X-Amz-Target"] = "Firehose_20150804.CreateDeliveryStream"
+
+	_, err = req.Do()
+
+	return deliveryStream, err
+}
+
+// listDeliveryStreamsRequest is the request to the ListDeliveryStreams API call.
+type listDeliveryStreamsRequest struct {
+	ExclusiveStartDeliveryStreamName string `json:",omitempty"`
+}
+
+// listDeliveryStreamsResult is the result of the ListDeliveryStreams API call
+type listDeliveryStreamsResult struct {
+	DeliveryStreamNames    []string
+	HasMoreDeliveryStreams bool
+}
+
+// ListDeliveryStreams lists every delivery stream in an account, following HasMoreDeliveryStreams through as many
+// calls as it takes. It returns a list of delivery streams and an error if it fails.
+// See http://docs.aws.amazon.com/firehose/latest/APIReference/API_ListDeliveryStreams.html for more details.
+func (s *FirehoseService) ListDeliveryStreams() ([]DeliveryStream, error) {
+	deliveryStreams := []DeliveryStream{}
+	body := listDeliveryStreamsRequest{}
+
+	for {
+		bodyAsJson, err := json.Marshal(body)
+
+		req := s.request()
+		req.Body = bodyAsJson
+		req.Headers["X-Amz-Target"] = "Firehose_20150804.ListDeliveryStreams"
+
+		resp, err := req.Do()
+		if err != nil {
+			return []DeliveryStream{}, err
+		}
+
+		result := listDeliveryStreamsResult{}
+		err = json.Unmarshal(resp, &result)
+		if err != nil {
+			return []DeliveryStream{}, err
+		}
+
+		for _, name := range result.DeliveryStreamNames {
+			deliveryStreams = append(deliveryStreams, DeliveryStream{Name: name, Service: s})
+		}
+		if !result.HasMoreDeliveryStreams || len(result.DeliveryStreamNames) == 0 {
+			return deliveryStreams, nil
+		}
+		body.ExclusiveStartDeliveryStreamName = result.DeliveryStreamNames[len(result.DeliveryStreamNames)-1]
+	}
+}
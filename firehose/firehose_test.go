@@ -0,0 +1,200 @@
+package firehose
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/controlgroup/gaws"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var notFoundError = firehoseError{Type: "ResourceNotFoundException", Message: "Could not find something"}
+
+func testHTTP404(w http.ResponseWriter, r *http.Request) {
+	b, _ := json.Marshal(notFoundError)
+
+	w.WriteHeader(404)
+	w.Write([]byte(b))
+}
+
+// fakeFirehose keeps delivery streams and the records put on them. It lists one delivery stream per call, and
+// fails the next failures records sent to PutRecordBatch with ServiceUnavailableException.
+type fakeFirehose struct {
+	mu       sync.Mutex
+	streams  map[string][]string // Record data by delivery stream name.
+	requests map[string][]map[string]interface{}
+	failures int
+}
+
+func newFakeFirehose() *fakeFirehose {
+	return &fakeFirehose{streams: map[string][]string{}, requests: map[string][]map[string]interface{}{}}
+}
+
+func (f *fakeFirehose) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	target := r.Header.Get("X-Amz-Target")
+	body, _ := ioutil.ReadAll(r.Body)
+	decoded := map[string]interface{}{}
+	json.Unmarshal(body, &decoded)
+	f.requests[target] = append(f.requests[target], decoded)
+	name, _ := decoded["DeliveryStreamName"].(string)
+
+	respond := func(v interface{}) {
+		b, _ := json.Marshal(v)
+		w.Write(b)
+	}
+
+	switch target {
+	case "Firehose_20150804.CreateDeliveryStream":
+		f.streams[name] = []string{}
+		respond(map[string]string{"DeliveryStreamARN": "arn:aws:firehose:us-east-1:000000000000:deliverystream/" + name})
+	case "Firehose_20150804.ListDeliveryStreams":
+		names := []string{}
+		for n := range f.streams {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		start, _ := decoded["ExclusiveStartDeliveryStreamName"].(string)
+		for i, n := range names {
+			if n > start {
+				respond(map[string]interface{}{"DeliveryStreamNames": []string{n}, "HasMoreDeliveryStreams": i < len(names)-1})
+				return
+			}
+		}
+		respond(map[string]interface{}{"DeliveryStreamNames": []string{}, "HasMoreDeliveryStreams": false})
+	case "Firehose_20150804.DescribeDeliveryStream":
+		if _, ok := f.streams[name]; !ok {
+			testHTTP404(w, r)
+			return
+		}
+		respond(map[string]interface{}{"DeliveryStreamDescription": map[string]interface{}{
+			"DeliveryStreamName":   name,
+			"DeliveryStreamStatus": "ACTIVE",
+			"DeliveryStreamType":   "DirectPut",
+			"Destinations":         []map[string]interface{}{{"DestinationId": "destinationId-000000000001", "S3DestinationDescription": map[string]interface{}{"BucketARN": "arn:aws:s3:::bucket", "BufferingHints": map[string]int{"SizeInMBs": 5, "IntervalInSeconds": 300}}}},
+		}})
+	case "Firehose_20150804.DeleteDeliveryStream":
+		delete(f.streams, name)
+		respond(map[string]string{})
+	case "Firehose_20150804.PutRecord":
+		data, _ := base64.StdEncoding.DecodeString(decoded["Record"].(map[string]interface{})["Data"].(string))
+		f.streams[name] = append(f.streams[name], string(data))
+		respond(map[string]string{"RecordId": fmt.Sprint(len(f.streams[name]))})
+	case "Firehose_20150804.PutRecordBatch":
+		responses := []PutRecordBatchResponseEntry{}
+		failed := 0
+		for _, r := range decoded["Records"].([]interface{}) {
+			if f.failures > 0 {
+				f.failures--
+				responses = append(responses, PutRecordBatchResponseEntry{ErrorCode: "ServiceUnavailableException", ErrorMessage: "Slow down."})
+				failed++
+				continue
+			}
+			data, _ := base64.StdEncoding.DecodeString(r.(map[string]interface{})["Data"].(string))
+			f.streams[name] = append(f.streams[name], string(data))
+			responses = append(responses, PutRecordBatchResponseEntry{RecordId: fmt.Sprint(len(f.streams[name]))})
+		}
+		respond(PutRecordBatchOutput{FailedPutCount: failed, RequestResponses: responses})
+	}
+}
+
+func TestDeliveryStreams(t *testing.T) {
+	Convey("Given a Firehose service", t, func() {
+		fake := newFakeFirehose()
+		ts := httptest.NewServer(fake)
+		defer ts.Close()
+		fs := FirehoseService{Endpoint: ts.URL}
+
+		config := DeliveryStreamConfiguration{S3DestinationConfiguration: &S3DestinationConfiguration{BucketARN: "arn:aws:s3:::bucket", RoleARN: "arn:aws:iam::000000000000:role/firehose"}}
+		foo, err := fs.CreateDeliveryStream("foo", config)
+		So(err, ShouldBeNil)
+		fs.CreateDeliveryStream("bar", config)
+
+		Convey("CreateDeliveryStream sends the destination", func() {
+			request := fake.requests["Firehose_20150804.CreateDeliveryStream"][0]
+			So(request["DeliveryStreamType"], ShouldEqual, "DirectPut")
+			So(request["S3DestinationConfiguration"].(map[string]interface{})["BucketARN"], ShouldEqual, "arn:aws:s3:::bucket")
+			So(request["KinesisStreamSourceConfiguration"], ShouldBeNil)
+		})
+		Convey("A Kinesis stream source makes a KinesisStreamAsSource delivery stream", func() {
+			config.KinesisStreamSourceConfiguration = &KinesisStreamSourceConfiguration{KinesisStreamARN: "arn:aws:kinesis:us-east-1:000000000000:stream/foo"}
+			fs.CreateDeliveryStream("baz", config)
+			So(fake.requests["Firehose_20150804.CreateDeliveryStream"][2]["DeliveryStreamType"], ShouldEqual, "KinesisStreamAsSource")
+		})
+		Convey("ListDeliveryStreams follows every page", func() {
+			streams, err := fs.ListDeliveryStreams()
+			So(err, ShouldBeNil)
+			So(len(streams), ShouldEqual, 2)
+			So(streams[0].Name, ShouldEqual, "bar")
+			So(streams[1].Name, ShouldEqual, "foo")
+			So(len(fake.requests["Firehose_20150804.ListDeliveryStreams"]), ShouldEqual, 2)
+		})
+		Convey("Describe returns the description", func() {
+			description, err := foo.Describe()
+			So(err, ShouldBeNil)
+			So(description.DeliveryStreamStatus, ShouldEqual, "ACTIVE")
+			So(description.Destinations[0].S3DestinationDescription.BufferingHints.SizeInMBs, ShouldEqual, 5)
+		})
+		Convey("Delete deletes the delivery stream", func() {
+			So(foo.Delete(), ShouldBeNil)
+			_, err := foo.Describe()
+			So(err, ShouldNotBeNil)
+		})
+		Convey("PutRecord puts the data and returns the record ID", func() {
+			id, err := foo.PutRecord([]byte("one\n"))
+			So(err, ShouldBeNil)
+			So(id, ShouldEqual, "1")
+			So(fake.streams["foo"], ShouldResemble, []string{"one\n"})
+		})
+		Convey("PutRecordBatch retries the records that fail", func() {
+			fake.failures = 1
+			output, err := foo.PutRecordBatch([][]byte{[]byte("one"), []byte("two"), []byte("three")})
+			So(err, ShouldBeNil)
+			So(output.FailedPutCount, ShouldEqual, 0)
+			So(len(fake.requests["Firehose_20150804.PutRecordBatch"]), ShouldEqual, 2)
+			So(fake.streams["foo"], ShouldResemble, []string{"two", "three", "one"})
+			So(output.RequestResponses[0].RecordId, ShouldEqual, "3")
+		})
+		Convey("PutRecordBatch gives up on records that keep failing", func() {
+			fake.failures = 10
+			defer func(tries int) { gaws.MaxTries = tries }(gaws.MaxTries)
+			gaws.MaxTries = 2
+			output, err := foo.PutRecordBatch([][]byte{[]byte("one")})
+			So(err, ShouldEqual, ErrPutRecordBatchFailed)
+			So(output.FailedPutCount, ShouldEqual, 1)
+			So(len(fake.requests["Firehose_20150804.PutRecordBatch"]), ShouldEqual, 2)
+			So(output.RequestResponses[0].ErrorCode, ShouldEqual, "ServiceUnavailableException")
+		})
+		Convey("PutRecordBatch splits more than 500 records into several calls", func() {
+			records := make([][]byte, 600)
+			for i := range records {
+				records[i] = []byte("x")
+			}
+			output, err := foo.PutRecordBatch(records)
+			So(err, ShouldBeNil)
+			So(len(output.RequestResponses), ShouldEqual, 600)
+			So(len(fake.requests["Firehose_20150804.PutRecordBatch"]), ShouldEqual, 2)
+		})
+	})
+	Convey("When the service returns errors", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(testHTTP404))
+		defer ts.Close()
+		fs := FirehoseService{Endpoint: ts.URL}
+
+		Convey("They are returned", func() {
+			_, err := fs.ListDeliveryStreams()
+			So(err.Error(), ShouldEqual, notFoundError.Error())
+			_, err = (&DeliveryStream{Name: "foo", Service: &fs}).PutRecord([]byte("one"))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -7,6 +7,8 @@ import (
 	"io/ioutil"
 	"math"
 	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/smartystreets/go-aws-auth"
@@ -15,6 +17,25 @@ import (
 // MaxTries is the number of times to retry a failing AWS request.
 var MaxTries int = 5
 
+var (
+	defaultHTTPClient = &http.Client{}
+	httpClient        atomic.Value
+)
+
+// HTTPClient returns the client used to send every AWS request.
+func HTTPClient() *http.Client {
+	if client, ok := httpClient.Load().(*http.Client); ok && client != nil {
+		return client
+	}
+	return defaultHTTPClient
+}
+
+// SetHTTPClient replaces the client used to send every AWS request, for example with one whose Transport records or
+// replays requests in tests. nil restores the default. It is safe to call while requests are being made.
+func SetHTTPClient(client *http.Client) {
+	httpClient.Store(client)
+}
+
 // gawsError is the error document returned from many AWS requests.
 type gawsError struct {
 	Type    string `json:"__type"`
@@ -23,6 +44,27 @@ type gawsError struct {
 
 var exceededRetriesError = gawsError{Type: "GawsExceededMaxRetries", Message: "The maximum number of retries for this request was exceeded."}
 
+// exceededRetries is the error Do returns after MaxTries attempts that all asked to be retried.
+type exceededRetries struct {
+	gawsError
+	last error // The error the RetryPredicate returned for the last attempt.
+}
+
+// IsExceededRetries reports whether err is the error Do returns after MaxTries attempts that all asked to be retried.
+func IsExceededRetries(err error) bool {
+	_, ok := err.(exceededRetries)
+	return ok
+}
+
+// LastRetryError returns the error of the last attempt if err is the error for exceeding MaxTries, such as the
+// throttling error that caused the retries. It returns nil for other errors.
+func LastRetryError(err error) error {
+	if e, ok := err.(exceededRetries); ok {
+		return e.last
+	}
+	return nil
+}
+
 // Error formats the gawsError into an error message.
 func (e gawsError) Error() string {
 	return fmt.Sprintf("%v: %v", e.Type, e.Message)
@@ -52,35 +94,84 @@ func (r *AWSRequest) getRequest() *http.Request {
 	return req
 }
 
+// operation names the request in metrics: its X-Amz-Target header, or its method if it has none.
+func (r *AWSRequest) operation() string {
+	if target := r.Headers["X-Amz-Target"]; target != "" {
+		return target
+	}
+	return r.Method
+}
+
 // Do makes the request to AWS and retries with an exponential backoff.
+// Every attempt is reported to DefaultMetrics and logged to DefaultLogger, along with each backoff and the final outcome.
 func (r *AWSRequest) Do() ([]byte, error) {
-	client := &http.Client{}
+	client := HTTPClient()
+	metrics := DefaultMetrics()
+	logger := DefaultLogger()
+	operation := r.operation()
 	var lastBody []byte
+	var lastFields Fields
+	var lastErr error
 
 	for try := 1; try < MaxTries; try++ {
 		req := r.getRequest()
+		start := time.Now()
 		resp, err := client.Do(req)
+		metrics.Add("gaws_request_sent_bytes_total", Labels{"operation": operation}, float64(len(r.Body)))
 
 		if err != nil {
+			recordAttempt(metrics, operation, try, "error", start)
+			logger.Log(LevelError, "aws request failed", Fields{"operation": operation, "attempt": try, "error": err.Error()})
 			return make([]byte, 0), err
 		}
 		defer resp.Body.Close()
 		body, err := ioutil.ReadAll(resp.Body)
+		status := strconv.Itoa(resp.StatusCode)
+		recordAttempt(metrics, operation, try, status, start)
+		metrics.Add("gaws_request_received_bytes_total", Labels{"operation": operation}, float64(len(body)))
+
+		fields := responseFields(operation, try, resp, body)
+		fields["duration"] = time.Since(start)
 
 		if err != nil {
+			logger.Log(LevelError, "aws request failed", fields.with("error", err.Error()))
 			return body, err
 		}
 
 		shouldRetry, err := r.RetryPredicate(resp.StatusCode, body)
+		if err != nil {
+			fields["error"] = err.Error()
+		}
+		logger.Log(LevelDebug, "aws request attempt", fields.with())
+
 		if shouldRetry {
 			lastBody = body
+			lastFields = fields
+			lastErr = err
+			metrics.Add("gaws_request_retries_total", Labels{"operation": operation, "status": status}, 1)
 
 			// Exponential backoff for the retry
-			sleepDuration := time.Duration(100 * math.Pow(2.0, float64(try)))
-			time.Sleep(sleepDuration * time.Millisecond)
+			sleepDuration := time.Duration(100*math.Pow(2.0, float64(try))) * time.Millisecond
+			logger.Log(LevelWarn, "aws request will be retried", fields.with("backoff", sleepDuration))
+			time.Sleep(sleepDuration)
 		} else {
+			if err != nil {
+				logger.Log(LevelError, "aws request failed", fields)
+			} else {
+				logger.Log(LevelDebug, "aws request succeeded", fields)
+			}
 			return body, err
 		}
 	}
-	return lastBody, exceededRetriesError
+	metrics.Add("gaws_request_exceeded_retries_total", Labels{"operation": operation}, 1)
+	if lastFields != nil {
+		logger.Log(LevelError, "aws request exceeded the maximum number of tries", lastFields)
+	}
+	return lastBody, exceededRetries{gawsError: exceededRetriesError, last: lastErr}
+}
+
+// recordAttempt reports one attempt at a request and how long it took. status is the HTTP status, or "error" if there was none.
+func recordAttempt(metrics Metrics, operation string, try int, status string, start time.Time) {
+	metrics.Add("gaws_request_attempts_total", Labels{"operation": operation, "attempt": strconv.Itoa(try), "status": status}, 1)
+	metrics.Observe("gaws_request_duration_seconds", Labels{"operation": operation, "status": status}, time.Since(start).Seconds())
 }
@@ -140,6 +140,16 @@ func TestThrottleRetry(t *testing.T) {
 			So(err.Error(), ShouldEqual, exceededRetriesError.Error())
 		})
 
+		Convey("IsExceededRetries should recognize the error", func() {
+			So(IsExceededRetries(err), ShouldBeTrue)
+			So(IsExceededRetries(notFoundError), ShouldBeFalse)
+		})
+
+		Convey("LastRetryError should return the throttling error", func() {
+			So(LastRetryError(err), ShouldResemble, throttlingError)
+			So(LastRetryError(notFoundError), ShouldBeNil)
+		})
+
 	})
 }
 
@@ -172,3 +182,30 @@ func TestBadRequest(t *testing.T) {
 		})
 	})
 }
+
+func TestHTTPClient(t *testing.T) {
+	Convey("Given a client set while requests are being made", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(testHTTP200))
+		defer ts.Close()
+
+		done := make(chan struct{})
+		go func() {
+			r := canonicalRequest()
+			r.URL = ts.URL
+			r.Do()
+			close(done)
+		}()
+		client := &http.Client{}
+		SetHTTPClient(client)
+		defer SetHTTPClient(nil)
+		<-done
+
+		Convey("It is the client requests use", func() {
+			So(HTTPClient(), ShouldEqual, client)
+		})
+		Convey("Setting nil restores the default", func() {
+			SetHTTPClient(nil)
+			So(HTTPClient(), ShouldEqual, defaultHTTPClient)
+		})
+	})
+}
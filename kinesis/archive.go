@@ -0,0 +1,341 @@
+package kinesis
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ArchivedRecord is a record saved by Dump. Data is the payload exactly as it was on the stream, so compressed payloads stay compressed.
+type ArchivedRecord struct {
+	ShardId        string
+	SequenceNumber string
+	PartitionKey   string
+	ArrivalTime    time.Time
+	Data           []byte
+}
+
+// ArchiveWriter saves ArchivedRecords.
+type ArchiveWriter interface {
+	Write(record ArchivedRecord) error
+}
+
+// ArchiveReader reads ArchivedRecords back in the order they were written. It returns io.EOF after the last one.
+type ArchiveReader interface {
+	Read() (ArchivedRecord, error)
+}
+
+type jsonArchiveWriter struct {
+	encoder *json.Encoder
+}
+
+// NewJSONArchiveWriter returns an ArchiveWriter that writes one JSON document per line. Data is Base64 encoded.
+func NewJSONArchiveWriter(w io.Writer) ArchiveWriter {
+	return &jsonArchiveWriter{encoder: json.NewEncoder(w)}
+}
+
+func (a *jsonArchiveWriter) Write(record ArchivedRecord) error {
+	return a.encoder.Encode(record)
+}
+
+type jsonArchiveReader struct {
+	decoder *json.Decoder
+}
+
+// NewJSONArchiveReader returns an ArchiveReader for archives written by NewJSONArchiveWriter.
+func NewJSONArchiveReader(r io.Reader) ArchiveReader {
+	return &jsonArchiveReader{decoder: json.NewDecoder(r)}
+}
+
+func (a *jsonArchiveReader) Read() (ArchivedRecord, error) {
+	record := ArchivedRecord{}
+	err := a.decoder.Decode(&record)
+	return record, err
+}
+
+type binaryArchiveWriter struct {
+	w *bufio.Writer
+}
+
+// NewBinaryArchiveWriter returns an ArchiveWriter for a compact binary format. Each record is its shard ID, sequence number,
+// partition key and data, each prefixed with its length as a varint, followed by the arrival time in nanoseconds since the epoch.
+// Every record is flushed to w as soon as it is written.
+func NewBinaryArchiveWriter(w io.Writer) ArchiveWriter {
+	return &binaryArchiveWriter{w: bufio.NewWriter(w)}
+}
+
+func (a *binaryArchiveWriter) Write(record ArchivedRecord) error {
+	fields := [][]byte{[]byte(record.ShardId), []byte(record.SequenceNumber), []byte(record.PartitionKey), record.Data}
+	prefix := make([]byte, binary.MaxVarintLen64)
+
+	for _, field := range fields {
+		n := binary.PutUvarint(prefix, uint64(len(field)))
+		if _, err := a.w.Write(prefix[:n]); err != nil {
+			return err
+		}
+		if _, err := a.w.Write(field); err != nil {
+			return err
+		}
+	}
+
+	var arrival int64
+	if !record.ArrivalTime.IsZero() {
+		arrival = record.ArrivalTime.UnixNano()
+	}
+	n := binary.PutVarint(prefix, arrival)
+	if _, err := a.w.Write(prefix[:n]); err != nil {
+		return err
+	}
+	return a.w.Flush()
+}
+
+type binaryArchiveReader struct {
+	r *bufio.Reader
+}
+
+// NewBinaryArchiveReader returns an ArchiveReader for archives written by NewBinaryArchiveWriter.
+func NewBinaryArchiveReader(r io.Reader) ArchiveReader {
+	return &binaryArchiveReader{r: bufio.NewReader(r)}
+}
+
+// maxArchivedFieldLength guards against allocating huge buffers for a corrupt archive. Kinesis records are at most 1 MB.
+const maxArchivedFieldLength = 1 << 24
+
+func (a *binaryArchiveReader) Read() (ArchivedRecord, error) {
+	fields := make([][]byte, 4)
+
+	for i := range fields {
+		length, err := binary.ReadUvarint(a.r)
+		if err == io.EOF && i > 0 {
+			return ArchivedRecord{}, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return ArchivedRecord{}, err
+		}
+		if length > maxArchivedFieldLength {
+			return ArchivedRecord{}, fmt.Errorf("kinesis: archived field of %v bytes is too long", length)
+		}
+
+		fields[i] = make([]byte, length)
+		if _, err := io.ReadFull(a.r, fields[i]); err != nil {
+			return ArchivedRecord{}, io.ErrUnexpectedEOF
+		}
+	}
+
+	arrival, err := binary.ReadVarint(a.r)
+	if err != nil {
+		return ArchivedRecord{}, io.ErrUnexpectedEOF
+	}
+
+	record := ArchivedRecord{ShardId: string(fields[0]), SequenceNumber: string(fields[1]), PartitionKey: string(fields[2]), Data: fields[3]}
+	if arrival != 0 {
+		record.ArrivalTime = time.Unix(0, arrival)
+	}
+	return record, nil
+}
+
+// DumpOptions controls which records Dump saves.
+type DumpOptions struct {
+	ShardIds   []string      // The shards to dump. Empty means every shard in the stream.
+	Position   StartPosition // Where to start reading each shard. Defaults to TrimHorizon.
+	Until      time.Time     // Stop reading a shard at the first record that arrived after this. Zero reads up to the tip of the shard.
+	MaxRecords int           // Stop after this many records in total. 0 means no limit.
+}
+
+// Dump reads records from the stream's shards and writes them to w in the order they arrived, across shards, so that
+// Replay can pace them. Each shard is read until it has been caught up with. It returns the number of records written.
+func (s *Stream) Dump(w ArchiveWriter, options DumpOptions) (int, error) {
+	shards, err := s.Shards()
+	if err != nil {
+		return 0, err
+	}
+
+	wanted := map[string]bool{}
+	for _, id := range options.ShardIds {
+		wanted[id] = true
+	}
+
+	selected := []*Shard{}
+	for i := range shards {
+		if len(wanted) > 0 && !wanted[shards[i].ShardId] {
+			continue
+		}
+		delete(wanted, shards[i].ShardId)
+		selected = append(selected, &shards[i])
+	}
+	for id := range wanted {
+		return 0, fmt.Errorf("kinesis: stream %v has no shard %v", s.Name, id)
+	}
+
+	cursors := []*dumpCursor{}
+	defer func() {
+		for _, cursor := range cursors {
+			cursor.reader.Stop()
+		}
+	}()
+	for _, shard := range selected {
+		cursors = append(cursors, newDumpCursor(shard, options))
+	}
+
+	for _, cursor := range cursors {
+		if err := cursor.advance(options.Until); err != nil {
+			return 0, err
+		}
+	}
+
+	written := 0
+	for options.MaxRecords <= 0 || written < options.MaxRecords {
+		var earliest *dumpCursor
+		for _, cursor := range cursors {
+			if !cursor.done && (earliest == nil || cursor.next.ArrivalTime().Before(earliest.next.ArrivalTime())) {
+				earliest = cursor
+			}
+		}
+		if earliest == nil {
+			break
+		}
+
+		record := earliest.next
+		data, err := base64.StdEncoding.DecodeString(record.Data)
+		if err != nil {
+			return written, err
+		}
+		archived := ArchivedRecord{ShardId: earliest.shard.ShardId, SequenceNumber: record.SequenceNumber, PartitionKey: record.PartitionKey, ArrivalTime: record.ArrivalTime(), Data: data}
+		if err := w.Write(archived); err != nil {
+			return written, err
+		}
+		written++
+
+		if err := earliest.advance(options.Until); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// dumpCursor holds the next record of a shard that Dump is reading.
+type dumpCursor struct {
+	shard   *Shard
+	reader  *ShardReader
+	records <-chan Record
+	errc    <-chan error
+	next    Record
+	done    bool // Whether the shard has no more records to dump.
+}
+
+func newDumpCursor(shard *Shard, options DumpOptions) *dumpCursor {
+	reader := &ShardReader{Shard: shard, Position: options.Position, StopAtLatest: true}
+	records, errc := reader.Start()
+	return &dumpCursor{shard: shard, reader: reader, records: records, errc: errc}
+}
+
+// advance waits for the shard's next record. The shard is done at its tip or at the first record that arrived after until.
+func (c *dumpCursor) advance(until time.Time) error {
+	select {
+	case record, ok := <-c.records:
+		if !ok || (!until.IsZero() && record.ArrivalTime().After(until)) {
+			c.done = true
+			return nil
+		}
+		c.next = record
+		return nil
+	case err := <-c.errc:
+		return err
+	}
+}
+
+// ReplayOptions controls how Replay puts archived records.
+type ReplayOptions struct {
+	// Pace waits between records for the time that passed between their original arrivals, divided by Speed.
+	// It expects the archive in arrival order, as Dump writes it; a record that arrived before the one read
+	// before it is put without waiting.
+	Pace  bool
+	Speed float64 // How much faster than the original to replay when pacing. Defaults to 1.
+}
+
+// ErrReplayFailed is returned when some records could not be put after gaws.MaxTries attempts.
+var ErrReplayFailed = errors.New("kinesis: some records could not be replayed")
+
+// replayBatchSize is the most records PutRecords accepts in one call.
+const replayBatchSize = MaxPutRecordsEntries
+
+// Replay puts every record from r onto the stream with PutRecords, keeping their partition keys, in batches of up to
+// replayBatchSize records and MaxPutRecordsBytes. The data is put as it was archived; the stream's Compressor is not
+// applied again. It returns the number of records put, and ErrRecordTooLarge for a record that is too large to put.
+func (s *Stream) Replay(r ArchiveReader, options ReplayOptions) (int, error) {
+	speed := options.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+
+	// Archived data is already compressed if it needs to be.
+	destination := *s
+	destination.Compressor = nil
+
+	put := 0
+	batch := []PutRecordsEntry{}
+	batchBytes := 0
+	var previousArrival time.Time
+	flush := func() error {
+		n, err := destination.putAll(batch)
+		put += n
+		batch = batch[:0]
+		batchBytes = 0
+		return replayError(err)
+	}
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return put, err
+		}
+
+		entry := PutRecordsEntry{PartitionKey: record.PartitionKey, Data: record.Data}
+		size := entrySize(entry)
+		if size > MaxRecordBytes {
+			return put, ErrRecordTooLarge
+		}
+
+		if options.Pace && !previousArrival.IsZero() && record.ArrivalTime.After(previousArrival) {
+			if err := flush(); err != nil {
+				return put, err
+			}
+			time.Sleep(time.Duration(float64(record.ArrivalTime.Sub(previousArrival)) / speed))
+		}
+		if record.ArrivalTime.After(previousArrival) {
+			previousArrival = record.ArrivalTime
+		}
+
+		if batchBytes+size > MaxPutRecordsBytes {
+			if err := flush(); err != nil {
+				return put, err
+			}
+		}
+		batch = append(batch, entry)
+		batchBytes += size
+		if len(batch) == replayBatchSize {
+			if err := flush(); err != nil {
+				return put, err
+			}
+		}
+	}
+
+	err := flush()
+	return put, err
+}
+
+// replayError reports records that could not be put as ErrReplayFailed.
+func replayError(err error) error {
+	if err == ErrPutRecordsFailed {
+		return ErrReplayFailed
+	}
+	return err
+}
@@ -0,0 +1,237 @@
+package kinesis
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/controlgroup/gaws/kinesis/kinesistest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var testArchivedRecords = []ArchivedRecord{
+	{ShardId: "shardId-000000000000", SequenceNumber: "1", PartitionKey: "a", ArrivalTime: time.Unix(1500000000, 0), Data: []byte("one")},
+	{ShardId: "shardId-000000000000", SequenceNumber: "2", PartitionKey: "b", Data: []byte{0xFF, 0x00}},
+}
+
+func TestArchives(t *testing.T) {
+	formats := map[string]func(*bytes.Buffer) (ArchiveWriter, ArchiveReader){
+		"JSON": func(b *bytes.Buffer) (ArchiveWriter, ArchiveReader) {
+			return NewJSONArchiveWriter(b), NewJSONArchiveReader(b)
+		},
+		"binary": func(b *bytes.Buffer) (ArchiveWriter, ArchiveReader) {
+			return NewBinaryArchiveWriter(b), NewBinaryArchiveReader(b)
+		},
+	}
+
+	for name, format := range formats {
+		Convey("Given records written to a "+name+" archive", t, func() {
+			buf := &bytes.Buffer{}
+			w, r := format(buf)
+			for _, record := range testArchivedRecords {
+				So(w.Write(record), ShouldBeNil)
+			}
+
+			Convey("They are read back the same, followed by io.EOF", func() {
+				for _, expected := range testArchivedRecords {
+					record, err := r.Read()
+					So(err, ShouldBeNil)
+					So(record.SequenceNumber, ShouldEqual, expected.SequenceNumber)
+					So(record.PartitionKey, ShouldEqual, expected.PartitionKey)
+					So(record.Data, ShouldResemble, expected.Data)
+					So(record.ArrivalTime.Equal(expected.ArrivalTime), ShouldBeTrue)
+				}
+				_, err := r.Read()
+				So(err, ShouldEqual, io.EOF)
+			})
+		})
+	}
+	Convey("Given a binary archive cut off partway through a record", t, func() {
+		buf := &bytes.Buffer{}
+		NewBinaryArchiveWriter(buf).Write(testArchivedRecords[0])
+		buf.Truncate(buf.Len() - 2)
+
+		Convey("Reading it returns io.ErrUnexpectedEOF", func() {
+			_, err := NewBinaryArchiveReader(buf).Read()
+			So(err, ShouldEqual, io.ErrUnexpectedEOF)
+		})
+	})
+}
+
+// dumpableStream serves a stream with one open shard holding two records.
+func dumpableStream(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	switch r.Header.Get("X-Amz-Target") {
+	case "Kinesis_20131202.DescribeStream":
+		w.Write([]byte(`{"StreamDescription": {"StreamStatus": "ACTIVE", "Shards": [{"ShardId": "shardId-000000000000", "HashKeyRange": {"StartingHashKey": "0", "EndingHashKey": "340282366920938463463374607431768211455"}}]}}`))
+	case "Kinesis_20131202.GetShardIterator":
+		w.Write([]byte(`{"ShardIterator": "first"}`))
+	case "Kinesis_20131202.GetRecords":
+		request := getRecordsRequest{}
+		json.Unmarshal(body, &request)
+		if request.ShardIterator == "first" {
+			w.Write([]byte(`{"MillisBehindLatest": 0, "NextShardIterator": "second", "Records": [
+				{"ApproximateArrivalTimestamp": 1500000000, "Data": "b25l", "PartitionKey": "a", "SequenceNumber": "1"},
+				{"ApproximateArrivalTimestamp": 1500000001, "Data": "dHdv", "PartitionKey": "b", "SequenceNumber": "2"}]}`))
+		} else {
+			w.Write([]byte(`{"MillisBehindLatest": 0, "NextShardIterator": "third", "Records": []}`))
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	Convey("Given a stream with two records", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(dumpableStream))
+		ks := KinesisService{Endpoint: ts.URL}
+		testStream := Stream{Name: "foo", Service: &ks}
+		buf := &bytes.Buffer{}
+
+		Convey("Dump writes both and stops at the tip of the shard", func() {
+			n, err := testStream.Dump(NewJSONArchiveWriter(buf), DumpOptions{})
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+
+			record, _ := NewJSONArchiveReader(buf).Read()
+			So(record.ShardId, ShouldEqual, "shardId-000000000000")
+			So(record.Data, ShouldResemble, []byte("one"))
+			So(record.ArrivalTime.Unix(), ShouldEqual, 1500000000)
+		})
+		Convey("Dump stops at MaxRecords", func() {
+			n, err := testStream.Dump(NewJSONArchiveWriter(buf), DumpOptions{MaxRecords: 1})
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+		})
+		Convey("Dump stops at records that arrived after Until", func() {
+			n, err := testStream.Dump(NewJSONArchiveWriter(buf), DumpOptions{Until: time.Unix(1500000000, 0)})
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+		})
+		Convey("Dump returns an error for a shard the stream does not have", func() {
+			_, err := testStream.Dump(NewJSONArchiveWriter(buf), DumpOptions{ShardIds: []string{"shardId-000000000009"}})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+// flakyPutRecords fails the first record of the first PutRecords call and records every entry it accepts.
+type flakyPutRecords struct {
+	mu       sync.Mutex
+	calls    int
+	accepted []putRecordsRequestEntry
+}
+
+func (f *flakyPutRecords) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	body, _ := ioutil.ReadAll(r.Body)
+	request := putRecordsRequest{}
+	json.Unmarshal(body, &request)
+	f.calls++
+
+	output := PutRecordsOutput{Records: make([]PutRecordsResultEntry, len(request.Records))}
+	for i, entry := range request.Records {
+		if f.calls == 1 && i == 0 {
+			output.FailedRecordCount++
+			output.Records[i].ErrorCode = "ProvisionedThroughputExceededException"
+			continue
+		}
+		f.accepted = append(f.accepted, entry)
+	}
+	b, _ := json.Marshal(output)
+	w.Write(b)
+}
+
+func TestDumpOrder(t *testing.T) {
+	Convey("Given a stream with two shards whose records arrived alternately", t, func() {
+		server := kinesistest.NewServer()
+		defer server.Close()
+		ks := KinesisService{Endpoint: server.URL}
+		stream, _ := ks.CreateStream("foo", 2)
+		shards, _ := stream.Shards()
+
+		for i, data := range []string{"one", "two", "six"} {
+			shard := shards[(i+1)%2]
+			stream.PutRecords([]PutRecordsEntry{{Data: []byte(data), PartitionKey: "a", ExplicitHashKey: shard.HashKeyRange.StartingHashKey}})
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		Convey("Dump writes them in the order they arrived", func() {
+			w := &sliceArchiveWriter{}
+			n, err := stream.Dump(w, DumpOptions{})
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 3)
+
+			data := []string{}
+			for _, record := range w.records {
+				data = append(data, string(record.Data))
+			}
+			So(data, ShouldResemble, []string{"one", "two", "six"})
+			So(w.records[0].ShardId, ShouldEqual, shards[1].ShardId)
+		})
+	})
+}
+
+func TestReplay(t *testing.T) {
+	Convey("Given an archive and a stream that throttles the first record once", t, func() {
+		buf := &bytes.Buffer{}
+		w := NewJSONArchiveWriter(buf)
+		for _, record := range testArchivedRecords {
+			w.Write(record)
+		}
+
+		server := &flakyPutRecords{}
+		ts := httptest.NewServer(server)
+		ks := KinesisService{Endpoint: ts.URL}
+		testStream := Stream{Name: "foo", Service: &ks, Compressor: GzipCompressor{}}
+
+		n, err := testStream.Replay(NewJSONArchiveReader(buf), ReplayOptions{})
+
+		Convey("Every record is put", func() {
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+			So(server.calls, ShouldEqual, 2)
+		})
+		Convey("Partition keys and data are kept as archived", func() {
+			So(len(server.accepted), ShouldEqual, 2)
+			So(server.accepted[1].PartitionKey, ShouldEqual, "a")
+			So(server.accepted[1].Data, ShouldEqual, base64.StdEncoding.EncodeToString([]byte("one")))
+		})
+	})
+}
+
+func TestReplayLargeRecords(t *testing.T) {
+	Convey("Given an archive of records that together are larger than MaxPutRecordsBytes", t, func() {
+		server := kinesistest.NewServer()
+		defer server.Close()
+		ks := KinesisService{Endpoint: server.URL}
+		stream, _ := ks.CreateStream("foo", 1)
+
+		buf := &bytes.Buffer{}
+		w := NewBinaryArchiveWriter(buf)
+		for i := 0; i < 12; i++ {
+			w.Write(ArchivedRecord{PartitionKey: "a", Data: make([]byte, 512<<10)})
+		}
+
+		Convey("Replay splits them into batches that PutRecords accepts", func() {
+			n, err := stream.Replay(NewBinaryArchiveReader(buf), ReplayOptions{})
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 12)
+			So(server.Requests("PutRecords"), ShouldEqual, 2)
+		})
+		Convey("Replay returns ErrRecordTooLarge for a record larger than MaxRecordBytes", func() {
+			buf.Reset()
+			w.Write(ArchivedRecord{PartitionKey: "a", Data: make([]byte, MaxRecordBytes)})
+			_, err := stream.Replay(NewBinaryArchiveReader(buf), ReplayOptions{})
+			So(err, ShouldEqual, ErrRecordTooLarge)
+		})
+	})
+}
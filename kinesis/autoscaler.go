@@ -0,0 +1,286 @@
+package kinesis
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/controlgroup/gaws"
+)
+
+// Per-shard throughput limits enforced by Kinesis.
+const (
+	ShardWriteBytesPerSecond   = 1024 * 1024     // Each shard accepts up to 1 MB of data per second.
+	ShardWriteRecordsPerSecond = 1000            // Each shard accepts up to 1000 records per second.
+	ShardReadBytesPerSecond    = 2 * 1024 * 1024 // Each shard serves up to 2 MB of data per second.
+)
+
+// ThroughputSample is the traffic a stream saw over Period.
+type ThroughputSample struct {
+	Period          time.Duration
+	IncomingBytes   int64 // Bytes put on the stream.
+	IncomingRecords int64 // Records put on the stream.
+	OutgoingBytes   int64 // Bytes read from the stream.
+	OutgoingRecords int64 // Records read from the stream.
+	WriteThrottles  int64 // Put requests rejected with ProvisionedThroughputExceededException.
+	ReadThrottles   int64 // GetRecords requests rejected with ProvisionedThroughputExceededException.
+}
+
+// MetricsSource provides throughput samples for a stream. Each call to Sample should cover the time since the previous call.
+type MetricsSource interface {
+	Sample(stream *Stream) (ThroughputSample, error)
+}
+
+// ThroughputCounter is a MetricsSource that callers feed by hand, for traffic gaws does not see.
+// RegistrySource counts the traffic of this process's own clients without that. It is safe for concurrent use.
+type ThroughputCounter struct {
+	mu      sync.Mutex
+	sample  ThroughputSample
+	started time.Time
+}
+
+// AddPut counts records and bytes put on the stream.
+func (c *ThroughputCounter) AddPut(records int, bytes int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.start()
+	c.sample.IncomingRecords += int64(records)
+	c.sample.IncomingBytes += int64(bytes)
+}
+
+// AddGet counts records and bytes read from the stream.
+func (c *ThroughputCounter) AddGet(records int, bytes int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.start()
+	c.sample.OutgoingRecords += int64(records)
+	c.sample.OutgoingBytes += int64(bytes)
+}
+
+// AddWriteThrottle counts a put that was throttled.
+func (c *ThroughputCounter) AddWriteThrottle() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.start()
+	c.sample.WriteThrottles++
+}
+
+// AddReadThrottle counts a read that was throttled.
+func (c *ThroughputCounter) AddReadThrottle() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.start()
+	c.sample.ReadThrottles++
+}
+
+// start begins the first sample period. Callers hold c.mu.
+func (c *ThroughputCounter) start() {
+	if c.started.IsZero() {
+		c.started = time.Now()
+	}
+}
+
+// Sample returns the counts since the previous call and resets them.
+func (c *ThroughputCounter) Sample(stream *Stream) (ThroughputSample, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	sample := c.sample
+	if !c.started.IsZero() {
+		sample.Period = now.Sub(c.started)
+	}
+
+	c.sample = ThroughputSample{}
+	c.started = now
+	return sample, nil
+}
+
+// RegistrySource is a MetricsSource that reads the traffic this process's own Streams, Producers and ShardReaders
+// report to a gaws.Registry, which must be installed with gaws.SetDefaultMetrics. Throttles are only counted from
+// ProvisionedThroughputExceededException responses, so server errors do not look like a need for more shards.
+// The first sample of a stream has no Period, so an Autoscaler holds its size until the second.
+// It is safe for concurrent use.
+type RegistrySource struct {
+	Registry *gaws.Registry
+
+	mu       sync.Mutex
+	previous map[string]registryTotals
+}
+
+// registryTotals are a stream's counters when it was last sampled.
+type registryTotals struct {
+	counts ThroughputSample
+	at     time.Time
+}
+
+// totals reads the stream's counters, summed over its shards.
+func (r *RegistrySource) totals(stream *Stream) ThroughputSample {
+	labels := gaws.Labels{"stream": stream.Name}
+	sum := func(name string) int64 {
+		return int64(r.Registry.Sum(name, labels))
+	}
+	return ThroughputSample{
+		IncomingBytes:   sum("kinesis_put_bytes_total"),
+		IncomingRecords: sum("kinesis_records_put_total"),
+		OutgoingBytes:   sum("kinesis_read_bytes_total"),
+		OutgoingRecords: sum("kinesis_records_read_total"),
+		WriteThrottles:  sum("kinesis_records_put_throttled_total"),
+		ReadThrottles:   sum("kinesis_read_throttles_total"),
+	}
+}
+
+// Sample returns how much the stream's counters grew since the previous call.
+func (r *RegistrySource) Sample(stream *Stream) (ThroughputSample, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	counts := r.totals(stream)
+	if r.previous == nil {
+		r.previous = map[string]registryTotals{}
+	}
+	previous, ok := r.previous[stream.Name]
+	r.previous[stream.Name] = registryTotals{counts: counts, at: now}
+	if !ok {
+		return ThroughputSample{}, nil
+	}
+
+	return ThroughputSample{
+		Period:          now.Sub(previous.at),
+		IncomingBytes:   counts.IncomingBytes - previous.counts.IncomingBytes,
+		IncomingRecords: counts.IncomingRecords - previous.counts.IncomingRecords,
+		OutgoingBytes:   counts.OutgoingBytes - previous.counts.OutgoingBytes,
+		OutgoingRecords: counts.OutgoingRecords - previous.counts.OutgoingRecords,
+		WriteThrottles:  counts.WriteThrottles - previous.counts.WriteThrottles,
+		ReadThrottles:   counts.ReadThrottles - previous.counts.ReadThrottles,
+	}, nil
+}
+
+// Autoscaler resizes a stream to fit the throughput reported by Source. It scales up when throughput nears
+// the per-shard limits or requests are throttled, and scales down when the stream is underused.
+type Autoscaler struct {
+	Stream            *Stream
+	Source            MetricsSource
+	MinShards         int           // The fewest open shards to scale down to. Defaults to 1.
+	MaxShards         int           // The most open shards to scale up to. Required.
+	TargetUtilization float64       // The fraction of per-shard capacity to aim for. Defaults to 0.7.
+	Interval          time.Duration // How often Run takes a sample. Defaults to one minute.
+	ScaleUpCooldown   time.Duration // The minimum time after a resize before scaling up again.
+	ScaleDownCooldown time.Duration // The minimum time after a resize before scaling down again.
+
+	// Resize changes the number of open shards. It defaults to Stream.Reshard.
+	Resize func(targetShardCount int) error
+
+	lastResize time.Time
+}
+
+// ErrNoMaxShards is returned by an Autoscaler without MaxShards set.
+var ErrNoMaxShards = errors.New("kinesis: autoscaler needs MaxShards")
+
+// DesiredShardCount returns the number of shards needed to serve sample at the target utilization, given the current count.
+// Any throttling asks for at least one more shard than there is now. The result is clamped to MinShards and MaxShards.
+func (a *Autoscaler) DesiredShardCount(current int, sample ThroughputSample) int {
+	utilization := a.TargetUtilization
+	if utilization <= 0 || utilization > 1 {
+		utilization = 0.7
+	}
+
+	// Without a period there is no rate to go on, so hold the current size.
+	desired := current
+	seconds := sample.Period.Seconds()
+	if seconds > 0 {
+		desired = 1
+		perSecond := func(count int64, limit float64) int {
+			return int(math.Ceil(float64(count) / seconds / (limit * utilization)))
+		}
+		desired = maxInt(desired, perSecond(sample.IncomingBytes, ShardWriteBytesPerSecond))
+		desired = maxInt(desired, perSecond(sample.IncomingRecords, ShardWriteRecordsPerSecond))
+		desired = maxInt(desired, perSecond(sample.OutgoingBytes, ShardReadBytesPerSecond))
+	}
+
+	if sample.WriteThrottles > 0 || sample.ReadThrottles > 0 {
+		desired = maxInt(desired, current+1)
+	}
+
+	minShards := maxInt(a.MinShards, 1)
+	if desired < minShards {
+		desired = minShards
+	}
+	if desired > a.MaxShards {
+		desired = a.MaxShards
+	}
+	return desired
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Step takes one sample and resizes the stream if it needs it and the cooldown has passed. It returns the number of open shards afterwards.
+func (a *Autoscaler) Step() (int, error) {
+	if a.MaxShards < 1 {
+		return 0, ErrNoMaxShards
+	}
+
+	sample, err := a.Source.Sample(a.Stream)
+	if err != nil {
+		return 0, err
+	}
+
+	shards, err := a.Stream.OpenShards()
+	if err != nil {
+		return 0, err
+	}
+	current := len(shards)
+
+	desired := a.DesiredShardCount(current, sample)
+	if desired == current {
+		return current, nil
+	}
+
+	cooldown := a.ScaleUpCooldown
+	if desired < current {
+		cooldown = a.ScaleDownCooldown
+	}
+	if !a.lastResize.IsZero() && time.Since(a.lastResize) < cooldown {
+		return current, nil
+	}
+
+	resize := a.Resize
+	if resize == nil {
+		resize = a.Stream.Reshard
+	}
+	if err := resize(desired); err != nil {
+		return current, err
+	}
+
+	a.lastResize = time.Now()
+	return desired, nil
+}
+
+// Run calls Step every Interval until stop is closed. It returns the first error Step returns.
+func (a *Autoscaler) Run(stop <-chan struct{}) error {
+	interval := a.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			if _, err := a.Step(); err != nil {
+				return err
+			}
+		}
+	}
+}
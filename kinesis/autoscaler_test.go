@@ -0,0 +1,187 @@
+package kinesis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/controlgroup/gaws"
+	"github.com/controlgroup/gaws/kinesis/kinesistest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fixedSource struct {
+	sample ThroughputSample
+}
+
+func (f fixedSource) Sample(stream *Stream) (ThroughputSample, error) {
+	return f.sample, nil
+}
+
+func TestDesiredShardCount(t *testing.T) {
+	Convey("Given an autoscaler allowing 1 to 10 shards at full utilization", t, func() {
+		a := Autoscaler{MinShards: 1, MaxShards: 10, TargetUtilization: 1}
+
+		Convey("An idle stream needs the minimum", func() {
+			So(a.DesiredShardCount(4, ThroughputSample{Period: time.Second}), ShouldEqual, 1)
+		})
+		Convey("Three and a half MB a second of writes needs four shards", func() {
+			sample := ThroughputSample{Period: 2 * time.Second, IncomingBytes: 7 * ShardWriteBytesPerSecond}
+			So(a.DesiredShardCount(1, sample), ShouldEqual, 4)
+		})
+		Convey("2500 records a second needs three shards", func() {
+			sample := ThroughputSample{Period: time.Second, IncomingRecords: 2500}
+			So(a.DesiredShardCount(1, sample), ShouldEqual, 3)
+		})
+		Convey("Throttling asks for one more shard than there is now", func() {
+			sample := ThroughputSample{Period: time.Second, WriteThrottles: 1}
+			So(a.DesiredShardCount(3, sample), ShouldEqual, 4)
+		})
+		Convey("The result never exceeds MaxShards", func() {
+			sample := ThroughputSample{Period: time.Second, IncomingRecords: 1000000}
+			So(a.DesiredShardCount(3, sample), ShouldEqual, 10)
+		})
+	})
+}
+
+func TestAutoscalerStep(t *testing.T) {
+	Convey("Given an autoscaler on a stream with two open shards", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(testDescribeStreamSuccess))
+		ks := KinesisService{Endpoint: ts.URL}
+		testStream := Stream{Name: "foo", Service: &ks}
+
+		resizes := []int{}
+		a := Autoscaler{
+			Stream:          &testStream,
+			MaxShards:       8,
+			ScaleUpCooldown: time.Hour,
+			Resize: func(target int) error {
+				resizes = append(resizes, target)
+				return nil
+			},
+		}
+
+		Convey("A throttled stream is scaled up once, then held by the cooldown", func() {
+			a.Source = fixedSource{ThroughputSample{Period: time.Second, WriteThrottles: 3}}
+
+			count, err := a.Step()
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 3)
+
+			count, err = a.Step()
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 2)
+			So(resizes, ShouldResemble, []int{3})
+		})
+		Convey("An idle stream is scaled down to one shard", func() {
+			a.Source = fixedSource{ThroughputSample{Period: time.Second}}
+
+			count, err := a.Step()
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+			So(resizes, ShouldResemble, []int{1})
+		})
+		Convey("Without MaxShards it returns an error", func() {
+			a.MaxShards = 0
+			_, err := a.Step()
+			So(err, ShouldEqual, ErrNoMaxShards)
+		})
+	})
+}
+
+func TestThroughputCounter(t *testing.T) {
+	Convey("Given a counter that has seen some traffic", t, func() {
+		c := ThroughputCounter{}
+		c.AddPut(10, 1000)
+		c.AddGet(5, 500)
+		c.AddWriteThrottle()
+
+		Convey("Sample returns the counts", func() {
+			sample, err := c.Sample(nil)
+			So(err, ShouldBeNil)
+			So(sample.IncomingRecords, ShouldEqual, 10)
+			So(sample.IncomingBytes, ShouldEqual, 1000)
+			So(sample.OutgoingRecords, ShouldEqual, 5)
+			So(sample.WriteThrottles, ShouldEqual, 1)
+			So(sample.Period, ShouldBeGreaterThan, 0)
+
+			Convey("And the next sample starts from zero", func() {
+				sample, _ = c.Sample(nil)
+				So(sample.IncomingRecords, ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func TestRegistrySource(t *testing.T) {
+	Convey("Given a registry receiving the metrics of a stream with two shards", t, func() {
+		registry := gaws.NewRegistry()
+		gaws.SetDefaultMetrics(registry)
+		defer gaws.SetDefaultMetrics(nil)
+
+		server := kinesistest.NewServer()
+		defer server.Close()
+		ks := KinesisService{Endpoint: server.URL}
+		stream, _ := ks.CreateStream("scaled", 2)
+		source := &RegistrySource{Registry: registry}
+
+		Convey("The first sample has no period", func() {
+			sample, err := source.Sample(&stream)
+			So(err, ShouldBeNil)
+			So(sample, ShouldResemble, ThroughputSample{})
+		})
+		Convey("Later samples count the traffic since the one before, over every shard", func() {
+			source.Sample(&stream)
+			stream.PutRecords([]PutRecordsEntry{{PartitionKey: "a", Data: []byte("one")}, {PartitionKey: "b", Data: []byte("two")}})
+			stream.PutRecord("c", []byte("six"))
+
+			shards, _ := stream.Shards()
+			for i := range shards {
+				reader := &ShardReader{Shard: &shards[i], StopAtLatest: true}
+				records, _ := reader.Start()
+				for range records {
+				}
+				reader.Stop()
+			}
+
+			sample, err := source.Sample(&stream)
+			So(err, ShouldBeNil)
+			So(sample.Period, ShouldBeGreaterThan, 0)
+			So(sample.IncomingRecords, ShouldEqual, 3)
+			So(sample.IncomingBytes, ShouldEqual, 9)
+			So(sample.OutgoingRecords, ShouldEqual, 3)
+			So(sample.OutgoingBytes, ShouldEqual, 9)
+
+			sample, _ = source.Sample(&stream)
+			So(sample.IncomingRecords, ShouldEqual, 0)
+		})
+		Convey("Only throughput exceeded responses count as throttles", func() {
+			maxTries := gaws.MaxTries
+			gaws.MaxTries = 2
+			defer func() { gaws.MaxTries = maxTries }()
+			source.Sample(&stream)
+
+			server.Throttle("PutRecord", 1)
+			So(stream.PutRecord("a", []byte("one")), ShouldNotBeNil)
+			server.InjectFaults("PutRecord", kinesistest.Fault{Status: 500, Type: "InternalFailure"})
+			So(stream.PutRecord("a", []byte("one")), ShouldNotBeNil)
+
+			shards, _ := stream.Shards()
+			read := func() {
+				reader := &ShardReader{Shard: &shards[0], StopAtLatest: true}
+				_, errc := reader.Start()
+				So(<-errc, ShouldNotBeNil)
+				reader.Stop()
+			}
+			server.Throttle("GetRecords", 1)
+			read()
+			server.InjectFaults("GetRecords", kinesistest.Fault{Status: 500, Type: "InternalFailure"})
+			read()
+
+			sample, _ := source.Sample(&stream)
+			So(sample.WriteThrottles, ShouldEqual, 1)
+			So(sample.ReadThrottles, ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,114 @@
+package kinesis
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ShardEnd is the checkpoint of a closed shard whose every record has been processed.
+const ShardEnd = "SHARD_END"
+
+// Checkpointer saves how far each shard has been processed, so a consumer can resume where it left off after a restart.
+// Implementations must be safe for concurrent use.
+type Checkpointer interface {
+	Checkpoint(shardId string, sequenceNumber string) error // Checkpoint saves the sequence number of the last record processed, or ShardEnd.
+	LastCheckpoint(shardId string) (string, error)          // LastCheckpoint returns the last saved checkpoint, or "" if there is none.
+}
+
+// checkpointPosition is where to resume a shard with the given checkpoint, or position if there is none.
+func checkpointPosition(checkpoint string, position StartPosition) StartPosition {
+	if checkpoint != "" {
+		return AfterSequence(checkpoint)
+	}
+	return position
+}
+
+// MemoryCheckpointer keeps checkpoints in memory. It is useful in tests and for consumers that do not need to survive a restart.
+type MemoryCheckpointer struct {
+	mu          sync.Mutex
+	checkpoints map[string]string
+}
+
+// Checkpoint saves sequenceNumber as the shard's checkpoint.
+func (m *MemoryCheckpointer) Checkpoint(shardId string, sequenceNumber string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.checkpoints == nil {
+		m.checkpoints = map[string]string{}
+	}
+	m.checkpoints[shardId] = sequenceNumber
+	return nil
+}
+
+// LastCheckpoint returns the shard's checkpoint.
+func (m *MemoryCheckpointer) LastCheckpoint(shardId string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.checkpoints[shardId], nil
+}
+
+// FileCheckpointer keeps checkpoints in a JSON file that maps shard IDs to sequence numbers. The file is replaced
+// atomically on every checkpoint, so it is never left half written.
+type FileCheckpointer struct {
+	Path string
+
+	mu          sync.Mutex
+	checkpoints map[string]string
+}
+
+// NewFileCheckpointer returns a FileCheckpointer for path, loading the checkpoints already in it if it exists.
+func NewFileCheckpointer(path string) (*FileCheckpointer, error) {
+	f := &FileCheckpointer{Path: path, checkpoints: map[string]string{}}
+
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(contents, &f.checkpoints); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Checkpoint saves sequenceNumber as the shard's checkpoint and writes the file.
+func (f *FileCheckpointer) Checkpoint(shardId string, sequenceNumber string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.checkpoints == nil {
+		f.checkpoints = map[string]string{}
+	}
+	f.checkpoints[shardId] = sequenceNumber
+
+	contents, err := json.MarshalIndent(f.checkpoints, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(filepath.Dir(f.Path), filepath.Base(f.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := temp.Write(contents); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return os.Rename(temp.Name(), f.Path)
+}
+
+// LastCheckpoint returns the shard's checkpoint.
+func (f *FileCheckpointer) LastCheckpoint(shardId string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.checkpoints[shardId], nil
+}
@@ -0,0 +1,52 @@
+package kinesis
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFileCheckpointer(t *testing.T) {
+	Convey("Given a file checkpointer", t, func() {
+		dir, _ := ioutil.TempDir("", "checkpoint")
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "checkpoints.json")
+
+		checkpointer, err := NewFileCheckpointer(path)
+		So(err, ShouldBeNil)
+
+		Convey("A shard without a checkpoint has none", func() {
+			checkpoint, err := checkpointer.LastCheckpoint("shardId-000000000000")
+			So(err, ShouldBeNil)
+			So(checkpoint, ShouldEqual, "")
+		})
+		Convey("Checkpoints survive opening the file again", func() {
+			So(checkpointer.Checkpoint("shardId-000000000000", "5"), ShouldBeNil)
+			So(checkpointer.Checkpoint("shardId-000000000001", ShardEnd), ShouldBeNil)
+
+			reopened, err := NewFileCheckpointer(path)
+			So(err, ShouldBeNil)
+			checkpoint, _ := reopened.LastCheckpoint("shardId-000000000000")
+			So(checkpoint, ShouldEqual, "5")
+			checkpoint, _ = reopened.LastCheckpoint("shardId-000000000001")
+			So(checkpoint, ShouldEqual, ShardEnd)
+		})
+		Convey("No temporary files are left behind", func() {
+			checkpointer.Checkpoint("shardId-000000000000", "5")
+			files, _ := ioutil.ReadDir(dir)
+			So(len(files), ShouldEqual, 1)
+		})
+	})
+	Convey("A checkpoint file that is not JSON is an error", t, func() {
+		file, _ := ioutil.TempFile("", "checkpoint")
+		defer os.Remove(file.Name())
+		file.WriteString("not JSON")
+		file.Close()
+
+		_, err := NewFileCheckpointer(file.Name())
+		So(err, ShouldNotBeNil)
+	})
+}
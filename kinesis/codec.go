@@ -0,0 +1,93 @@
+package kinesis
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Codec turns values into record payloads and back.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// DefaultCodec is the codec used by Stream.PutValue and Record.Decode when no other is given.
+var DefaultCodec Codec = JSONCodec{}
+
+// JSONCodec encodes values with encoding/json.
+type JSONCodec struct{}
+
+// Marshal encodes v as JSON.
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes JSON data into v.
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// GobCodec encodes values with encoding/gob. Each record carries its own type information, so records can be decoded independently.
+type GobCodec struct{}
+
+// Marshal encodes v as a gob.
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+// Unmarshal decodes gob data into v.
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// ProtoMessage is a protocol buffer message with generated Marshal and Unmarshal methods, as produced by gogoprotobuf and similar generators.
+type ProtoMessage interface {
+	Marshal() ([]byte, error)
+	Unmarshal(data []byte) error
+}
+
+// ErrNotProtoMessage is returned by ProtoCodec for values that do not implement ProtoMessage.
+var ErrNotProtoMessage = errors.New("kinesis: value does not implement ProtoMessage")
+
+// ProtoCodec encodes protocol buffer messages prefixed with their length as a varint, the same framing as writeDelimitedTo in other protobuf libraries.
+type ProtoCodec struct{}
+
+// Marshal encodes v, which must be a ProtoMessage, with a varint length prefix.
+func (ProtoCodec) Marshal(v interface{}) ([]byte, error) {
+	message, ok := v.(ProtoMessage)
+	if !ok {
+		return nil, ErrNotProtoMessage
+	}
+
+	body, err := message.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(prefix, uint64(len(body)))
+	return append(prefix[:n], body...), nil
+}
+
+// Unmarshal checks the length prefix of data and decodes the message into v, which must be a ProtoMessage.
+func (ProtoCodec) Unmarshal(data []byte, v interface{}) error {
+	message, ok := v.(ProtoMessage)
+	if !ok {
+		return ErrNotProtoMessage
+	}
+
+	length, n := binary.Uvarint(data)
+	if n <= 0 {
+		return errors.New("kinesis: invalid length prefix")
+	}
+	if uint64(len(data)-n) != length {
+		return fmt.Errorf("kinesis: length prefix says %v bytes but there are %v", length, len(data)-n)
+	}
+	return message.Unmarshal(data[n:])
+}
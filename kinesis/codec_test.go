@@ -0,0 +1,113 @@
+package kinesis
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testEvent struct {
+	Name  string
+	Count int
+}
+
+// testProto stands in for a generated protocol buffer message. It marshals to its text.
+type testProto struct {
+	Text string
+}
+
+func (p *testProto) Marshal() ([]byte, error) {
+	return []byte(p.Text), nil
+}
+
+func (p *testProto) Unmarshal(data []byte) error {
+	p.Text = string(data)
+	return nil
+}
+
+func TestCodecs(t *testing.T) {
+	Convey("Given a value", t, func() {
+		event := testEvent{Name: "click", Count: 3}
+
+		for name, codec := range map[string]Codec{"JSON": JSONCodec{}, "gob": GobCodec{}} {
+			Convey("It survives a round trip through the "+name+" codec", func() {
+				data, err := codec.Marshal(event)
+				So(err, ShouldBeNil)
+
+				result := testEvent{}
+				So(codec.Unmarshal(data, &result), ShouldBeNil)
+				So(result, ShouldResemble, event)
+			})
+		}
+	})
+	Convey("Given a protocol buffer message", t, func() {
+		message := &testProto{Text: "hello"}
+
+		Convey("The proto codec prefixes it with its length", func() {
+			data, err := ProtoCodec{}.Marshal(message)
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, []byte("\x05hello"))
+
+			result := &testProto{}
+			So(ProtoCodec{}.Unmarshal(data, result), ShouldBeNil)
+			So(result.Text, ShouldEqual, "hello")
+		})
+		Convey("The proto codec rejects data whose length does not match its prefix", func() {
+			So(ProtoCodec{}.Unmarshal([]byte("\x09hello"), &testProto{}), ShouldNotBeNil)
+		})
+		Convey("The proto codec rejects values that are not messages", func() {
+			_, err := ProtoCodec{}.Marshal(testEvent{})
+			So(err, ShouldEqual, ErrNotProtoMessage)
+		})
+	})
+}
+
+func TestPutValue(t *testing.T) {
+	Convey("Given a stream on a server that records PutRecord requests", t, func() {
+		sent := putRecordRequest{}
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &sent)
+			w.Write([]byte("{}"))
+		}))
+		ks := KinesisService{Endpoint: ts.URL}
+		testStream := Stream{Name: "foo", Service: &ks}
+
+		Convey("PutValue sends the value encoded with the default codec", func() {
+			err := testStream.PutValue("key", testEvent{Name: "click", Count: 3})
+			So(err, ShouldBeNil)
+			So(sent.PartitionKey, ShouldEqual, "key")
+
+			record := Record{Data: sent.Data}
+			result := testEvent{}
+			So(record.Decode(&result), ShouldBeNil)
+			So(result, ShouldResemble, testEvent{Name: "click", Count: 3})
+		})
+		Convey("PutValue uses the stream's codec when it has one", func() {
+			testStream.Codec = GobCodec{}
+			err := testStream.PutValue("key", testEvent{Name: "click", Count: 3})
+			So(err, ShouldBeNil)
+
+			record := Record{Data: sent.Data}
+			result := testEvent{}
+			So(record.DecodeWith(GobCodec{}, &result), ShouldBeNil)
+			So(result.Count, ShouldEqual, 3)
+		})
+		Convey("PutValue returns an error for values the codec cannot encode", func() {
+			err := testStream.PutValue("key", make(chan int))
+			So(err, ShouldNotBeNil)
+		})
+	})
+	Convey("When I Decode a record that is not valid JSON", t, func() {
+		record := Record{Data: base64.StdEncoding.EncodeToString([]byte("not json"))}
+		err := record.Decode(&testEvent{})
+		Convey("There is an error", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
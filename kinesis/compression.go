@@ -0,0 +1,190 @@
+package kinesis
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// compressionMagic starts every compressed payload. 0xFF never appears in UTF-8 text, so JSON and other text payloads cannot be mistaken for compressed ones.
+var compressionMagic = []byte{0xFF, 'K', 'Z'}
+
+// A compressed payload is compressionMagic, then the Compressor's ID, then the compressed data.
+const compressionHeaderLength = 4
+
+// MaxDecompressedBytes is the most a payload may decompress to. A record holds at most MaxRecordBytes, but a small
+// payload can decompress to gigabytes, so decompressing stops here.
+const MaxDecompressedBytes = 64 << 20
+
+// ErrDecompressedTooLarge is returned when a payload decompresses to more than MaxDecompressedBytes.
+var ErrDecompressedTooLarge = errors.New("kinesis: decompressed payload exceeds MaxDecompressedBytes")
+
+// Compressor compresses record payloads. ID is written into the payload header so readers know how to decompress it,
+// and must be unique among registered compressors.
+type Compressor interface {
+	ID() byte
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[byte]Compressor{}
+)
+
+// RegisterCompressor makes a Compressor available for decompressing records. The gzip and zlib compressors are registered already.
+func RegisterCompressor(c Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[c.ID()] = c
+}
+
+func init() {
+	RegisterCompressor(GzipCompressor{})
+	RegisterCompressor(ZlibCompressor{})
+}
+
+// Compress compresses data with c and adds the header. If compressing does not make data smaller, data is returned as is.
+func Compress(c Compressor, data []byte) ([]byte, error) {
+	compressed, err := c.Compress(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(compressed)+compressionHeaderLength >= len(data) {
+		return data, nil
+	}
+
+	result := make([]byte, 0, len(compressed)+compressionHeaderLength)
+	result = append(result, compressionMagic...)
+	result = append(result, c.ID())
+	return append(result, compressed...), nil
+}
+
+// Decompress decompresses data that has a compression header, using the registered Compressor it names. Data without
+// a header is returned as is. So is data whose header names no registered Compressor, or that the Compressor cannot
+// decompress, because a binary payload can start with the header by chance. gzip and zlib check the integrity of
+// what they decompress, so a payload they accept was compressed by them. A payload that decompresses to more than
+// MaxDecompressedBytes returns ErrDecompressedTooLarge.
+func Decompress(data []byte) ([]byte, error) {
+	if len(data) < compressionHeaderLength || !bytes.HasPrefix(data, compressionMagic) {
+		return data, nil
+	}
+
+	id := data[len(compressionMagic)]
+	compressorsMu.RLock()
+	c, ok := compressors[id]
+	compressorsMu.RUnlock()
+	if !ok {
+		return data, nil
+	}
+	result, err := c.Decompress(data[compressionHeaderLength:])
+	if err == ErrDecompressedTooLarge {
+		return nil, err
+	}
+	if err != nil {
+		return data, nil
+	}
+	return result, nil
+}
+
+// compress compresses data with the stream's Compressor, if it has one.
+func (s *Stream) compress(data []byte) ([]byte, error) {
+	if s.Compressor == nil {
+		return data, nil
+	}
+	return Compress(s.Compressor, data)
+}
+
+// GzipCompressor compresses payloads with gzip.
+type GzipCompressor struct {
+	Level int // A compress/gzip level. 0 uses gzip.DefaultCompression.
+}
+
+// ID returns 1.
+func (GzipCompressor) ID() byte { return 1 }
+
+// Compress gzips data.
+func (g GzipCompressor) Compress(data []byte) ([]byte, error) {
+	level := g.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress gunzips data.
+func (GzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readDecompressed(r)
+}
+
+// ZlibCompressor compresses payloads with zlib.
+type ZlibCompressor struct {
+	Level int // A compress/zlib level. 0 uses zlib.DefaultCompression.
+}
+
+// ID returns 2.
+func (ZlibCompressor) ID() byte { return 2 }
+
+// Compress deflates data in the zlib format.
+func (z ZlibCompressor) Compress(data []byte) ([]byte, error) {
+	level := z.Level
+	if level == 0 {
+		level = zlib.DefaultCompression
+	}
+
+	var buf bytes.Buffer
+	w, err := zlib.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress inflates zlib data.
+func (ZlibCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readDecompressed(r)
+}
+
+// readDecompressed reads all of r, up to MaxDecompressedBytes.
+func readDecompressed(r io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, MaxDecompressedBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxDecompressedBytes {
+		return nil, ErrDecompressedTooLarge
+	}
+	return data, nil
+}
@@ -0,0 +1,96 @@
+package kinesis
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var compressibleData = bytes.Repeat([]byte(`{"event":"click","page":"/home"}`), 50)
+
+func TestCompression(t *testing.T) {
+	for name, c := range map[string]Compressor{"gzip": GzipCompressor{}, "zlib": ZlibCompressor{}} {
+		Convey("Given data compressed with "+name, t, func() {
+			compressed, err := Compress(c, compressibleData)
+			So(err, ShouldBeNil)
+
+			Convey("It is smaller and starts with the header", func() {
+				So(len(compressed), ShouldBeLessThan, len(compressibleData))
+				So(compressed[:3], ShouldResemble, compressionMagic)
+				So(compressed[3], ShouldEqual, c.ID())
+			})
+			Convey("Decompress returns the original data", func() {
+				result, err := Decompress(compressed)
+				So(err, ShouldBeNil)
+				So(result, ShouldResemble, compressibleData)
+			})
+		})
+		Convey("Given data compressed with "+name+" that decompresses to more than MaxDecompressedBytes", t, func() {
+			compressed, err := Compress(c, make([]byte, MaxDecompressedBytes+1))
+			So(err, ShouldBeNil)
+			So(len(compressed), ShouldBeLessThanOrEqualTo, MaxRecordBytes)
+
+			Convey("Decompress returns ErrDecompressedTooLarge", func() {
+				result, err := Decompress(compressed)
+				So(err, ShouldEqual, ErrDecompressedTooLarge)
+				So(result, ShouldBeNil)
+			})
+		})
+	}
+	Convey("Given data that does not compress", t, func() {
+		data := []byte("hi")
+		compressed, err := Compress(GzipCompressor{}, data)
+
+		Convey("It is left as is", func() {
+			So(err, ShouldBeNil)
+			So(compressed, ShouldResemble, data)
+		})
+	})
+	Convey("Given binary data that starts with the header by chance", t, func() {
+		Convey("With an unknown compressor ID, Decompress returns it as is", func() {
+			data := []byte{0xFF, 'K', 'Z', 99, 1, 2, 3}
+			result, err := Decompress(data)
+			So(err, ShouldBeNil)
+			So(result, ShouldResemble, data)
+		})
+		Convey("With a registered compressor ID that cannot decompress it, Decompress returns it as is", func() {
+			data := []byte{0xFF, 'K', 'Z', GzipCompressor{}.ID(), 1, 2, 3}
+			result, err := Decompress(data)
+			So(err, ShouldBeNil)
+			So(result, ShouldResemble, data)
+		})
+	})
+}
+
+func TestCompressedRecords(t *testing.T) {
+	Convey("Given a stream with a gzip compressor on a server that records PutRecord requests", t, func() {
+		sent := putRecordRequest{}
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &sent)
+			w.Write([]byte("{}"))
+		}))
+		ks := KinesisService{Endpoint: ts.URL}
+		testStream := Stream{Name: "foo", Service: &ks, Compressor: GzipCompressor{}}
+
+		err := testStream.PutRecord("key", compressibleData)
+		So(err, ShouldBeNil)
+
+		Convey("The data sent is compressed", func() {
+			raw, _ := base64.StdEncoding.DecodeString(sent.Data)
+			So(len(raw), ShouldBeLessThan, len(compressibleData))
+		})
+		Convey("Bytes on the resulting record returns the original data", func() {
+			record := Record{Data: sent.Data}
+			result, err := record.Bytes()
+			So(err, ShouldBeNil)
+			So(result, ShouldResemble, compressibleData)
+		})
+	})
+}
@@ -0,0 +1,107 @@
+package kinesis
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// DeadLetter is a record that its handler failed on, with where it came from and why it failed.
+type DeadLetter struct {
+	Record     Record
+	Err        error
+	StreamName string
+	ShardId    string
+	Attempts   int
+	FailedAt   time.Time
+}
+
+// deadLetterDocument is how the sinks in this package save a DeadLetter. The record's Data stays Base64 encoded as it was read.
+type deadLetterDocument struct {
+	Record     Record
+	Error      string
+	StreamName string
+	ShardId    string
+	Attempts   int
+	FailedAt   time.Time
+	Truncated  bool `json:",omitempty"` // Whether Record.Data was left out to fit the letter in a record.
+}
+
+func (d DeadLetter) document() deadLetterDocument {
+	document := deadLetterDocument{Record: d.Record, StreamName: d.StreamName, ShardId: d.ShardId, Attempts: d.Attempts, FailedAt: d.FailedAt}
+	if d.Err != nil {
+		document.Error = d.Err.Error()
+	}
+	return document
+}
+
+// DeadLetterSink saves records that could not be processed so they can be looked at or processed again later.
+type DeadLetterSink interface {
+	Send(letter DeadLetter) error
+}
+
+// FileDeadLetterSink appends dead letters to a file, one JSON document per line. It is safe for concurrent use.
+type FileDeadLetterSink struct {
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// NewFileDeadLetterSink opens path for appending, creating it if it does not exist.
+func NewFileDeadLetterSink(path string) (*FileDeadLetterSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileDeadLetterSink{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// Send appends letter to the file.
+func (s *FileDeadLetterSink) Send(letter DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.encoder.Encode(letter.document())
+}
+
+// Close closes the file.
+func (s *FileDeadLetterSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// StreamDeadLetterSink puts dead letters on another Kinesis stream as JSON documents, keyed by the failed record's partition key.
+// A letter too large for a record leaves out the record's data and is marked Truncated; the record can still be found in
+// its shard by its sequence number until it expires.
+type StreamDeadLetterSink struct {
+	Stream *Stream
+}
+
+// maxDeadLetterErrorBytes is the most of a truncated letter's error message that is kept.
+const maxDeadLetterErrorBytes = 1 << 10
+
+// Send puts letter on the stream.
+func (s *StreamDeadLetterSink) Send(letter DeadLetter) error {
+	partitionKey := letter.Record.PartitionKey
+	if partitionKey == "" {
+		partitionKey = letter.ShardId
+	}
+
+	document := letter.document()
+	data, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	if len(data)+len(partitionKey) > MaxRecordBytes {
+		document.Record.Data = ""
+		document.Truncated = true
+		if len(document.Error) > maxDeadLetterErrorBytes {
+			document.Error = document.Error[:maxDeadLetterErrorBytes]
+		}
+		if data, err = json.Marshal(document); err != nil {
+			return err
+		}
+	}
+	return s.Stream.PutRecord(partitionKey, data)
+}
@@ -0,0 +1,256 @@
+package kinesis
+
+import (
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// DefaultDedupKeys is how many keys a Deduplicator remembers when it has no Store.
+const DefaultDedupKeys = 100000
+
+// DedupKeyFunc returns the key that identifies a record for deduplication. Records with the same key are duplicates.
+type DedupKeyFunc func(record Record) (string, error)
+
+// SequenceNumberKey identifies records by their sequence number, which catches records read twice, for example after a
+// consumer restarts from a checkpoint.
+func SequenceNumberKey(record Record) (string, error) {
+	return record.SequenceNumber, nil
+}
+
+// DataDigestKey identifies records by a digest of their data, which also catches a payload that was put twice.
+func DataDigestKey(record Record) (string, error) {
+	sum := sha1.Sum([]byte(record.Data))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// DedupStore remembers the keys a Deduplicator has seen. Implementations may forget keys early to bound their size,
+// at the cost of letting some duplicates through.
+type DedupStore interface {
+	Seen(key string, since time.Time) (bool, error) // Seen reports whether key was added at or after since.
+	Add(key string, at time.Time) error             // Add remembers key as seen at at.
+	Expire(before time.Time) error                  // Expire forgets the keys added before before.
+
+	// SeenOrAdd reports whether key was added at or after since, and if it was not, adds it at at, as one atomic step.
+	SeenOrAdd(key string, since time.Time, at time.Time) (bool, error)
+}
+
+// MemoryDedupStore is a DedupStore that keeps keys in memory, forgetting the oldest once it holds MaxKeys.
+// It is safe for concurrent use.
+type MemoryDedupStore struct {
+	MaxKeys int // The most keys to remember. 0 means no limit.
+
+	mu    sync.Mutex
+	order *list.List // Keys in the order they were added.
+	keys  map[string]*list.Element
+}
+
+type dedupEntry struct {
+	key string
+	at  time.Time
+}
+
+// NewMemoryDedupStore returns a store that remembers up to maxKeys keys.
+func NewMemoryDedupStore(maxKeys int) *MemoryDedupStore {
+	return &MemoryDedupStore{MaxKeys: maxKeys}
+}
+
+func (m *MemoryDedupStore) init() {
+	if m.keys == nil {
+		m.keys = map[string]*list.Element{}
+		m.order = list.New()
+	}
+}
+
+// Seen reports whether key was added at or after since.
+func (m *MemoryDedupStore) Seen(key string, since time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	element, ok := m.keys[key]
+	if !ok {
+		return false, nil
+	}
+	return !element.Value.(*dedupEntry).at.Before(since), nil
+}
+
+// Add remembers key as seen at at, forgetting the oldest key if the store is full.
+func (m *MemoryDedupStore) Add(key string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+	m.add(key, at)
+	return nil
+}
+
+// SeenOrAdd reports whether key was added at or after since, and adds it at at if it was not.
+func (m *MemoryDedupStore) SeenOrAdd(key string, since time.Time, at time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	if element, ok := m.keys[key]; ok && !element.Value.(*dedupEntry).at.Before(since) {
+		return true, nil
+	}
+	m.add(key, at)
+	return false, nil
+}
+
+// add remembers key. Callers hold m.mu.
+func (m *MemoryDedupStore) add(key string, at time.Time) {
+	if element, ok := m.keys[key]; ok {
+		element.Value.(*dedupEntry).at = at
+		m.order.MoveToBack(element)
+		return
+	}
+	m.keys[key] = m.order.PushBack(&dedupEntry{key: key, at: at})
+
+	for m.MaxKeys > 0 && m.order.Len() > m.MaxKeys {
+		m.remove(m.order.Front())
+	}
+}
+
+// Expire forgets the keys added before before.
+func (m *MemoryDedupStore) Expire(before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	for front := m.order.Front(); front != nil && front.Value.(*dedupEntry).at.Before(before); front = m.order.Front() {
+		m.remove(front)
+	}
+	return nil
+}
+
+// Len returns the number of keys the store remembers.
+func (m *MemoryDedupStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.keys)
+}
+
+func (m *MemoryDedupStore) remove(element *list.Element) {
+	m.order.Remove(element)
+	delete(m.keys, element.Value.(*dedupEntry).key)
+}
+
+// Deduplicator drops records whose key it has already seen within Window, so that downstream sinks see each record once.
+// It is safe for concurrent use.
+type Deduplicator struct {
+	Key    DedupKeyFunc  // Identifies records. Defaults to SequenceNumberKey.
+	Window time.Duration // How long a key is remembered. 0 remembers keys until the Store forgets them.
+	Store  DedupStore    // Remembers keys. Defaults to a MemoryDedupStore holding DefaultDedupKeys keys.
+
+	mu       sync.Mutex
+	now      func() time.Time
+	inFlight map[string]chan struct{} // Keys of the records Handler is processing. Each channel is closed when its record is done.
+}
+
+func (d *Deduplicator) clock() time.Time {
+	if d.now != nil {
+		return d.now()
+	}
+	return time.Now()
+}
+
+func (d *Deduplicator) store() DedupStore {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.Store == nil {
+		d.Store = NewMemoryDedupStore(DefaultDedupKeys)
+	}
+	return d.Store
+}
+
+func (d *Deduplicator) key(record Record) (string, error) {
+	if d.Key == nil {
+		return SequenceNumberKey(record)
+	}
+	return d.Key(record)
+}
+
+// since expires keys older than the window and returns the earliest time a key must have been added to be remembered.
+func (d *Deduplicator) since(store DedupStore, now time.Time) (time.Time, error) {
+	if d.Window <= 0 {
+		return time.Time{}, nil
+	}
+	since := now.Add(-d.Window)
+	return since, store.Expire(since)
+}
+
+// IsDuplicate reports whether the record's key has been seen within the window, and remembers it if it has not.
+func (d *Deduplicator) IsDuplicate(record Record) (bool, error) {
+	key, err := d.key(record)
+	if err != nil {
+		return false, err
+	}
+	store := d.store()
+	now := d.clock()
+
+	since, err := d.since(store, now)
+	if err != nil {
+		return false, err
+	}
+	return store.SeenOrAdd(key, since, now)
+}
+
+// claim waits until no other call to Handler is processing a record with key, then marks key as being processed.
+// The returned channel is passed to release when the record is done.
+func (d *Deduplicator) claim(key string) chan struct{} {
+	for {
+		d.mu.Lock()
+		if d.inFlight == nil {
+			d.inFlight = map[string]chan struct{}{}
+		}
+		busy, ok := d.inFlight[key]
+		if !ok {
+			done := make(chan struct{})
+			d.inFlight[key] = done
+			d.mu.Unlock()
+			return done
+		}
+		d.mu.Unlock()
+		<-busy
+	}
+}
+
+// release marks key as no longer being processed and wakes the calls waiting for it.
+func (d *Deduplicator) release(key string, done chan struct{}) {
+	d.mu.Lock()
+	delete(d.inFlight, key)
+	d.mu.Unlock()
+	close(done)
+}
+
+// Handler wraps next so that it is not called for duplicate records. A record is only remembered once next has
+// processed it without error, so a record that fails can be tried again. Deliveries of the same record that arrive
+// while it is being processed wait for the outcome, so next is never running twice for one record.
+func (d *Deduplicator) Handler(next RecordHandler) RecordHandler {
+	return func(record Record) error {
+		key, err := d.key(record)
+		if err != nil {
+			return err
+		}
+		store := d.store()
+
+		done := d.claim(key)
+		defer d.release(key, done)
+
+		now := d.clock()
+		since, err := d.since(store, now)
+		if err != nil {
+			return err
+		}
+		seen, err := store.Seen(key, since)
+		if err != nil || seen {
+			return err
+		}
+		if err := next(record); err != nil {
+			return err
+		}
+		return store.Add(key, d.clock())
+	}
+}
@@ -0,0 +1,138 @@
+package kinesis
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMemoryDedupStore(t *testing.T) {
+	Convey("Given a store that holds two keys", t, func() {
+		store := NewMemoryDedupStore(2)
+		start := time.Unix(1000, 0)
+		store.Add("a", start)
+		store.Add("b", start.Add(time.Second))
+
+		Convey("It remembers the keys it was given", func() {
+			seen, err := store.Seen("a", time.Time{})
+			So(err, ShouldBeNil)
+			So(seen, ShouldBeTrue)
+			seen, _ = store.Seen("c", time.Time{})
+			So(seen, ShouldBeFalse)
+		})
+		Convey("Keys added before since are not seen", func() {
+			seen, _ := store.Seen("a", start.Add(time.Second))
+			So(seen, ShouldBeFalse)
+		})
+		Convey("Adding a third key forgets the oldest", func() {
+			store.Add("c", start.Add(2*time.Second))
+			So(store.Len(), ShouldEqual, 2)
+			seen, _ := store.Seen("a", time.Time{})
+			So(seen, ShouldBeFalse)
+		})
+		Convey("Expire forgets keys added before the time", func() {
+			store.Expire(start.Add(time.Second))
+			So(store.Len(), ShouldEqual, 1)
+			seen, _ := store.Seen("b", time.Time{})
+			So(seen, ShouldBeTrue)
+		})
+	})
+}
+
+func TestDeduplicator(t *testing.T) {
+	Convey("Given a deduplicator with a one minute window", t, func() {
+		clock := &fakeClock{now: time.Unix(1000, 0)}
+		dedup := &Deduplicator{Window: time.Minute, now: clock.Now}
+		record := Record{Data: "b25l", SequenceNumber: "1"}
+
+		Convey("A record is a duplicate the second time it is seen", func() {
+			duplicate, err := dedup.IsDuplicate(record)
+			So(err, ShouldBeNil)
+			So(duplicate, ShouldBeFalse)
+			duplicate, _ = dedup.IsDuplicate(record)
+			So(duplicate, ShouldBeTrue)
+		})
+		Convey("A record is not a duplicate once the window has passed", func() {
+			dedup.IsDuplicate(record)
+			clock.Sleep(2 * time.Minute)
+			duplicate, _ := dedup.IsDuplicate(record)
+			So(duplicate, ShouldBeFalse)
+		})
+		Convey("With DataDigestKey, the same payload at another sequence number is a duplicate", func() {
+			dedup.Key = DataDigestKey
+			dedup.IsDuplicate(record)
+			duplicate, _ := dedup.IsDuplicate(Record{Data: "b25l", SequenceNumber: "2"})
+			So(duplicate, ShouldBeTrue)
+		})
+		Convey("Handler calls the next handler once per record", func() {
+			calls := 0
+			handler := dedup.Handler(func(record Record) error {
+				calls++
+				return nil
+			})
+			So(handler(record), ShouldBeNil)
+			So(handler(record), ShouldBeNil)
+			So(calls, ShouldEqual, 1)
+		})
+		Convey("Handler does not remember records that failed", func() {
+			calls := 0
+			handler := dedup.Handler(func(record Record) error {
+				calls++
+				if calls == 1 {
+					return errors.New("failed")
+				}
+				return nil
+			})
+			So(handler(record), ShouldNotBeNil)
+			So(handler(record), ShouldBeNil)
+			So(calls, ShouldEqual, 2)
+		})
+		Convey("Concurrent deliveries of a record are only let through once", func() {
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			misses := 0
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if duplicate, _ := dedup.IsDuplicate(record); !duplicate {
+						mu.Lock()
+						misses++
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+			So(misses, ShouldEqual, 1)
+		})
+		Convey("Handler calls the next handler once for concurrent deliveries of a record", func() {
+			var mu sync.Mutex
+			calls := 0
+			handler := dedup.Handler(func(record Record) error {
+				mu.Lock()
+				calls++
+				mu.Unlock()
+				time.Sleep(time.Millisecond)
+				return nil
+			})
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					handler(record)
+				}()
+			}
+			wg.Wait()
+			So(calls, ShouldEqual, 1)
+		})
+		Convey("An error from the key function is returned", func() {
+			dedup.Key = func(record Record) (string, error) { return "", errors.New("no id") }
+			_, err := dedup.IsDuplicate(record)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
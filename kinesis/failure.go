@@ -0,0 +1,105 @@
+package kinesis
+
+import (
+	"fmt"
+	"time"
+)
+
+// RecordHandler processes one record. Returning an error marks the record as failed.
+type RecordHandler func(record Record) error
+
+// FailureAction is what Process does with a record once its handler has failed on every try.
+type FailureAction int
+
+const (
+	StopReading FailureAction = iota // Stop reading the shard and return a *RecordError.
+	SkipRecord                       // Go on to the next record.
+)
+
+// FailurePolicy decides what happens to records that their handler fails on, such as records that cannot be decoded.
+// The zero value tries each record once and stops at the first failure.
+type FailurePolicy struct {
+	Retries    int            // How many more times to try a record after it first fails.
+	RetryDelay time.Duration  // How long to wait between tries.
+	Action     FailureAction  // What to do once the retries are used up.
+	DeadLetter DeadLetterSink // Optional. Receives every record that fails, whether it is skipped or stops the reader.
+}
+
+// RecordError is returned by Process when a record fails and the policy is StopReading.
+type RecordError struct {
+	ShardId        string
+	SequenceNumber string
+	Attempts       int
+	Err            error // The error from the last try.
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("kinesis: record %v in shard %v failed after %v attempts: %v", e.SequenceNumber, e.ShardId, e.Attempts, e.Err)
+}
+
+// Process starts the reader and calls handler for each record, in order, applying policy to the records it fails on.
+// It returns nil when the record channel is closed or the reader is stopped, and otherwise the error that ended it.
+// LastSequenceNumber only moves past a record once the handler has succeeded on it or the policy has skipped it.
+func (r *ShardReader) Process(handler RecordHandler, policy FailurePolicy) error {
+	r.mu.Lock()
+	r.processing = true
+	r.mu.Unlock()
+
+	records, errc := r.Start()
+	defer r.Stop()
+
+	r.mu.Lock()
+	stop := r.stop
+	r.mu.Unlock()
+
+	for {
+		select {
+		case record, ok := <-records:
+			if !ok {
+				return nil
+			}
+			if err := r.handle(record, handler, policy); err != nil {
+				return err
+			}
+			r.mu.Lock()
+			r.lastSequenceNumber = record.SequenceNumber
+			r.mu.Unlock()
+		case err := <-errc:
+			return err
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// handle tries a record until it succeeds or the policy's retries are used up, then sends it to the dead-letter sink.
+// It returns an error if the reader should stop.
+func (r *ShardReader) handle(record Record, handler RecordHandler, policy FailurePolicy) error {
+	var err error
+	attempts := 0
+	for attempts <= policy.Retries {
+		if attempts > 0 && policy.RetryDelay > 0 {
+			time.Sleep(policy.RetryDelay)
+		}
+		attempts++
+		if err = handler(record); err == nil {
+			return nil
+		}
+	}
+
+	if policy.DeadLetter != nil {
+		letter := DeadLetter{Record: record, Err: err, ShardId: r.Shard.ShardId, Attempts: attempts, FailedAt: time.Now()}
+		if r.Shard.stream != nil {
+			letter.StreamName = r.Shard.stream.Name
+		}
+		// A record that cannot be saved must not be skipped, or it would be lost.
+		if sendErr := policy.DeadLetter.Send(letter); sendErr != nil {
+			return sendErr
+		}
+	}
+
+	if policy.Action == SkipRecord {
+		return nil
+	}
+	return &RecordError{ShardId: r.Shard.ShardId, SequenceNumber: record.SequenceNumber, Attempts: attempts, Err: err}
+}
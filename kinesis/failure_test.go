@@ -0,0 +1,170 @@
+package kinesis
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/controlgroup/gaws/kinesis/kinesistest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// memoryDeadLetterSink keeps dead letters in a slice.
+type memoryDeadLetterSink struct {
+	letters []DeadLetter
+}
+
+func (m *memoryDeadLetterSink) Send(letter DeadLetter) error {
+	m.letters = append(m.letters, letter)
+	return nil
+}
+
+func TestProcess(t *testing.T) {
+	Convey("Given a shard with a poison record between two good ones", t, func() {
+		server := kinesistest.NewServer()
+		defer server.Close()
+		ks := KinesisService{Endpoint: server.URL}
+		stream, _ := ks.CreateStream("foo", 1)
+		for _, value := range []string{"one", "poison", "three"} {
+			stream.PutRecord("a", []byte(value))
+		}
+		shards, _ := stream.Shards()
+
+		tries := map[string]int{}
+		handled := []string{}
+		handler := func(record Record) error {
+			data, _ := record.Bytes()
+			tries[string(data)]++
+			if string(data) == "poison" {
+				return errors.New("cannot decode")
+			}
+			handled = append(handled, string(data))
+			return nil
+		}
+		reader := &ShardReader{Shard: &shards[0], StopAtLatest: true}
+		sink := &memoryDeadLetterSink{}
+
+		Convey("The default policy stops at the poison record", func() {
+			err := reader.Process(handler, FailurePolicy{})
+			recordErr, ok := err.(*RecordError)
+			So(ok, ShouldBeTrue)
+			So(recordErr.ShardId, ShouldEqual, shards[0].ShardId)
+			So(recordErr.Attempts, ShouldEqual, 1)
+			So(recordErr.Err.Error(), ShouldEqual, "cannot decode")
+			So(handled, ShouldResemble, []string{"one"})
+
+			Convey("Resuming after the last record processed reads the poison record again", func() {
+				resumed := &ShardReader{Shard: &shards[0], Position: AfterSequence(reader.LastSequenceNumber()), StopAtLatest: true}
+				err := resumed.Process(func(record Record) error {
+					data, _ := record.Bytes()
+					handled = append(handled, string(data))
+					return nil
+				}, FailurePolicy{})
+				So(err, ShouldBeNil)
+				So(handled, ShouldResemble, []string{"one", "poison", "three"})
+			})
+		})
+		Convey("Skipping retries the record, sends it to the sink and carries on", func() {
+			err := reader.Process(handler, FailurePolicy{Retries: 2, Action: SkipRecord, DeadLetter: sink})
+			So(err, ShouldBeNil)
+			So(tries["poison"], ShouldEqual, 3)
+			So(handled, ShouldResemble, []string{"one", "three"})
+
+			So(len(sink.letters), ShouldEqual, 1)
+			So(sink.letters[0].StreamName, ShouldEqual, "foo")
+			So(sink.letters[0].ShardId, ShouldEqual, shards[0].ShardId)
+			So(sink.letters[0].Attempts, ShouldEqual, 3)
+			So(sink.letters[0].Err.Error(), ShouldEqual, "cannot decode")
+			So(sink.letters[0].Record.Data, ShouldEqual, "cG9pc29u")
+		})
+		Convey("Stopping also sends the record to the sink", func() {
+			err := reader.Process(handler, FailurePolicy{DeadLetter: sink})
+			So(err, ShouldNotBeNil)
+			So(len(sink.letters), ShouldEqual, 1)
+		})
+	})
+}
+
+func TestDeadLetterSinks(t *testing.T) {
+	letter := DeadLetter{Record: Record{Data: "cG9pc29u", PartitionKey: "a", SequenceNumber: "2"}, Err: errors.New("cannot decode"), StreamName: "foo", ShardId: "shardId-000000000000", Attempts: 3}
+
+	Convey("Given a file dead-letter sink", t, func() {
+		dir, _ := ioutil.TempDir("", "deadletter")
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "dead.jsonl")
+
+		sink, err := NewFileDeadLetterSink(path)
+		So(err, ShouldBeNil)
+		So(sink.Send(letter), ShouldBeNil)
+		So(sink.Send(letter), ShouldBeNil)
+		So(sink.Close(), ShouldBeNil)
+
+		Convey("Each letter is a line of JSON", func() {
+			contents, _ := ioutil.ReadFile(path)
+			lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+			So(len(lines), ShouldEqual, 2)
+
+			document := deadLetterDocument{}
+			So(json.Unmarshal([]byte(lines[0]), &document), ShouldBeNil)
+			So(document.Error, ShouldEqual, "cannot decode")
+			So(document.Record.SequenceNumber, ShouldEqual, "2")
+			So(document.Attempts, ShouldEqual, 3)
+		})
+		Convey("Opening it again appends to the file", func() {
+			sink, _ := NewFileDeadLetterSink(path)
+			sink.Send(letter)
+			sink.Close()
+			contents, _ := ioutil.ReadFile(path)
+			So(strings.Count(string(contents), "\n"), ShouldEqual, 3)
+		})
+	})
+
+	Convey("Given a stream dead-letter sink", t, func() {
+		server := kinesistest.NewServer()
+		defer server.Close()
+		ks := KinesisService{Endpoint: server.URL}
+		deadStream, _ := ks.CreateStream("dead", 1)
+		sink := &StreamDeadLetterSink{Stream: &deadStream}
+
+		So(sink.Send(letter), ShouldBeNil)
+
+		Convey("The letter is put on the stream as JSON", func() {
+			shards, _ := deadStream.Shards()
+			reader := &ShardReader{Shard: &shards[0], StopAtLatest: true}
+			records, _ := reader.Start()
+			record := <-records
+			So(record.PartitionKey, ShouldEqual, "a")
+
+			document := deadLetterDocument{}
+			So(record.Decode(&document), ShouldBeNil)
+			So(document.StreamName, ShouldEqual, "foo")
+			So(document.Record.Data, ShouldEqual, "cG9pc29u")
+			So(document.Truncated, ShouldBeFalse)
+		})
+		Convey("A letter for a record near the size limit leaves out its data", func() {
+			large := letter
+			large.Record.Data = base64.StdEncoding.EncodeToString(make([]byte, MaxRecordBytes-10))
+			large.Record.SequenceNumber = "3"
+			So(sink.Send(large), ShouldBeNil)
+
+			shards, _ := deadStream.Shards()
+			reader := &ShardReader{Shard: &shards[0], StopAtLatest: true}
+			records, _ := reader.Start()
+			<-records
+			record := <-records
+			reader.Stop()
+
+			document := deadLetterDocument{}
+			So(record.Decode(&document), ShouldBeNil)
+			So(document.Truncated, ShouldBeTrue)
+			So(document.Record.Data, ShouldEqual, "")
+			So(document.Record.SequenceNumber, ShouldEqual, "3")
+			So(document.Error, ShouldEqual, "cannot decode")
+		})
+	})
+}
@@ -0,0 +1,156 @@
+package kinesis
+
+import (
+	"sync"
+	"time"
+
+	"github.com/controlgroup/gaws"
+)
+
+// Per-shard read limits enforced by Kinesis, shared by every reader of the shard.
+const (
+	ShardGetRecordsCallsPerSecond = 5 // Each shard serves up to 5 GetRecords calls per second.
+)
+
+// ReadGovernor spaces out GetRecords calls so that the readers of a shard in one process stay within the shard's
+// read limits. It waits longer after an empty batch and backs off exponentially when reads are throttled.
+// Share one ReadGovernor between every ShardReader in a process that reads the same shards. It is safe for concurrent use.
+type ReadGovernor struct {
+	CallsPerSecond float64       // GetRecords calls allowed per shard per second. Defaults to ShardGetRecordsCallsPerSecond.
+	BytesPerSecond float64       // Bytes allowed per shard per second. Defaults to ShardReadBytesPerSecond.
+	IdleDelay      time.Duration // How long to wait after a call that returned no records. Defaults to one second.
+	MinBackoff     time.Duration // The first wait after a throttled call. Defaults to 200 milliseconds.
+	MaxBackoff     time.Duration // The longest wait after repeated throttled calls. Defaults to ten seconds.
+	MaxThrottles   int           // How many throttled calls in a row a reader backs off from before it stops with the error. Defaults to 10.
+
+	mu     sync.Mutex
+	shards map[string]*shardGovernor
+}
+
+// shardGovernor is the state of one shard.
+type shardGovernor struct {
+	calls     *TokenBucket
+	bytes     *TokenBucket
+	notBefore time.Time     // No call may be made before this, after an empty or throttled call.
+	backoff   time.Duration // The current throttling backoff. 0 means the last call was not throttled.
+}
+
+func (g *ReadGovernor) shard(key string) *shardGovernor {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.shards == nil {
+		g.shards = map[string]*shardGovernor{}
+	}
+	sg, ok := g.shards[key]
+	if !ok {
+		calls, bytes := g.CallsPerSecond, g.BytesPerSecond
+		if calls <= 0 {
+			calls = ShardGetRecordsCallsPerSecond
+		}
+		if bytes <= 0 {
+			bytes = ShardReadBytesPerSecond
+		}
+		// A burst of one call keeps calls evenly spaced instead of letting several readers fire at once.
+		sg = &shardGovernor{calls: NewTokenBucket(calls, 1), bytes: NewTokenBucket(bytes, bytes)}
+		g.shards[key] = sg
+	}
+	return sg
+}
+
+// shardKey identifies a shard across streams.
+func shardKey(shard *Shard) string {
+	if shard.stream == nil {
+		return shard.ShardId
+	}
+	return shard.stream.Name + "/" + shard.ShardId
+}
+
+// Wait blocks until the shard may be sent another GetRecords call.
+func (g *ReadGovernor) Wait(shard *Shard) {
+	g.wait(shard, nil)
+}
+
+// wait is Wait, but gives up and returns false as soon as stop is closed.
+func (g *ReadGovernor) wait(shard *Shard, stop <-chan struct{}) bool {
+	sg := g.shard(shardKey(shard))
+
+	g.mu.Lock()
+	pause := sg.notBefore.Sub(time.Now())
+	g.mu.Unlock()
+
+	// Waiting for zero bytes waits out any debt left by the bytes the last calls returned.
+	return sleepOrStop(pause, stop) && sleepOrStop(sg.bytes.delay(0), stop) && sleepOrStop(sg.calls.delay(1), stop)
+}
+
+// sleepOrStop sleeps for d and reports whether it did so before stop was closed.
+func sleepOrStop(d time.Duration, stop <-chan struct{}) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// Observe records the result of a GetRecords call on the shard so the governor can adapt the next wait.
+func (g *ReadGovernor) Observe(shard *Shard, records int, bytes int, err error) {
+	sg := g.shard(shardKey(shard))
+
+	if err == nil && bytes > 0 {
+		sg.bytes.Take(float64(bytes))
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch {
+	case isThrottlingError(err):
+		minBackoff, maxBackoff := g.MinBackoff, g.MaxBackoff
+		if minBackoff <= 0 {
+			minBackoff = 200 * time.Millisecond
+		}
+		if maxBackoff <= 0 {
+			maxBackoff = 10 * time.Second
+		}
+		sg.backoff *= 2
+		if sg.backoff < minBackoff {
+			sg.backoff = minBackoff
+		}
+		if sg.backoff > maxBackoff {
+			sg.backoff = maxBackoff
+		}
+		sg.notBefore = time.Now().Add(sg.backoff)
+	case err != nil:
+	case records == 0:
+		idle := g.IdleDelay
+		if idle <= 0 {
+			idle = time.Second
+		}
+		sg.backoff = 0
+		sg.notBefore = time.Now().Add(idle)
+	default:
+		sg.backoff = 0
+	}
+}
+
+// maxThrottles is MaxThrottles or its default.
+func (g *ReadGovernor) maxThrottles() int {
+	if g.MaxThrottles <= 0 {
+		return 10
+	}
+	return g.MaxThrottles
+}
+
+// isThrottlingError reports whether err means the shard's read limit was exceeded. AWSRequest.Do retries throttled
+// requests itself, so a throttle usually arrives as the error for exceeding gaws.MaxTries, which counts as a throttle
+// only if its last attempt was throttled.
+func isThrottlingError(err error) bool {
+	return isErrorType(err, "ProvisionedThroughputExceededException") ||
+		isErrorType(gaws.LastRetryError(err), "ProvisionedThroughputExceededException")
+}
@@ -0,0 +1,116 @@
+package kinesis
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/controlgroup/gaws"
+	"github.com/controlgroup/gaws/kinesis/kinesistest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var testThrottle = kinesisError{Type: "ProvisionedThroughputExceededException", Message: "Rate exceeded"}
+
+func TestReadGovernor(t *testing.T) {
+	Convey("Given a governor and a shard", t, func() {
+		g := &ReadGovernor{CallsPerSecond: 1000, IdleDelay: 50 * time.Millisecond, MinBackoff: 10 * time.Millisecond, MaxBackoff: 25 * time.Millisecond}
+		shard := &Shard{ShardId: "shardId-000000000000"}
+
+		Convey("Throttled calls back off exponentially up to MaxBackoff", func() {
+			g.Observe(shard, 0, 0, testThrottle)
+			So(g.shard(shardKey(shard)).backoff, ShouldEqual, 10*time.Millisecond)
+			g.Observe(shard, 0, 0, testThrottle)
+			So(g.shard(shardKey(shard)).backoff, ShouldEqual, 20*time.Millisecond)
+			g.Observe(shard, 0, 0, testThrottle)
+			So(g.shard(shardKey(shard)).backoff, ShouldEqual, 25*time.Millisecond)
+
+			Convey("And a successful call resets the backoff", func() {
+				g.Observe(shard, 1, 10, nil)
+				So(g.shard(shardKey(shard)).backoff, ShouldEqual, 0)
+			})
+		})
+		Convey("A call that returned no records makes the next one wait IdleDelay", func() {
+			g.Observe(shard, 0, 0, nil)
+			started := time.Now()
+			g.Wait(shard)
+			So(time.Since(started), ShouldBeGreaterThanOrEqualTo, 40*time.Millisecond)
+		})
+		Convey("Exceeded retries are throttles only if the last attempt was throttled", func() {
+			So(isThrottlingError(testThrottle), ShouldBeTrue)
+			So(isThrottlingError(errors.New("boom")), ShouldBeFalse)
+		})
+		Convey("Other errors do not change the wait", func() {
+			g.Observe(shard, 0, 0, errors.New("boom"))
+			started := time.Now()
+			g.Wait(shard)
+			So(time.Since(started), ShouldBeLessThan, 40*time.Millisecond)
+		})
+	})
+	Convey("Given a governor allowing 20 calls a second", t, func() {
+		g := &ReadGovernor{CallsPerSecond: 20}
+		shard := &Shard{ShardId: "shardId-000000000000"}
+
+		Convey("Calls for the same shard are spaced out", func() {
+			started := time.Now()
+			for i := 0; i < 3; i++ {
+				g.Wait(shard)
+			}
+			So(time.Since(started), ShouldBeGreaterThanOrEqualTo, 90*time.Millisecond)
+		})
+	})
+}
+
+func TestShardReaderWithGovernor(t *testing.T) {
+	Convey("Given a shard whose first read is throttled past the retry limit", t, func() {
+		maxTries := gaws.MaxTries
+		gaws.MaxTries = 2
+		defer func() { gaws.MaxTries = maxTries }()
+
+		server := kinesistest.NewServer()
+		defer server.Close()
+		ks := KinesisService{Endpoint: server.URL}
+		stream, _ := ks.CreateStream("foo", 1)
+		stream.PutRecord("a", []byte("one"))
+		shards, _ := stream.OpenShards()
+
+		server.Throttle("GetRecords", 1)
+
+		Convey("A reader with a governor backs off and carries on", func() {
+			reader := &ShardReader{Shard: &shards[0], StopAtLatest: true, Governor: &ReadGovernor{MinBackoff: time.Millisecond}}
+			c, errc := reader.Start()
+
+			count := 0
+			for range c {
+				count++
+			}
+			So(count, ShouldEqual, 1)
+			So(len(errc), ShouldEqual, 0)
+		})
+		Convey("A reader with a governor stops with the error once it has been throttled MaxThrottles times in a row", func() {
+			server.Throttle("GetRecords", 3)
+			reader := &ShardReader{Shard: &shards[0], Governor: &ReadGovernor{MinBackoff: time.Millisecond, MaxThrottles: 3}}
+			_, errc := reader.Start()
+			So(gaws.IsExceededRetries(<-errc), ShouldBeTrue)
+			So(server.Requests("GetRecords"), ShouldEqual, 4)
+		})
+		Convey("A reader with a governor stops on server errors that do not go away", func() {
+			for i := 0; i < 3; i++ {
+				server.InjectFaults("GetRecords", kinesistest.Fault{Status: 500, Type: "InternalFailure"})
+			}
+			reader := &ShardReader{Shard: &shards[0], Governor: &ReadGovernor{MinBackoff: time.Millisecond, MaxThrottles: 2}}
+			_, errc := reader.Start()
+			So(gaws.IsExceededRetries(<-errc), ShouldBeTrue)
+
+			Convey("Without retrying them as throttles", func() {
+				// The first request is the throttle every test starts with; the second is the server error.
+				So(server.Requests("GetRecords"), ShouldEqual, 2)
+			})
+		})
+		Convey("A reader without a governor stops with the error", func() {
+			reader := &ShardReader{Shard: &shards[0]}
+			_, errc := reader.Start()
+			So(gaws.IsExceededRetries(<-errc), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,75 @@
+package kinesis
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// MaxHashKey is the largest hash key a Kinesis stream can hold, 2^128 - 1.
+var MaxHashKey = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+
+// ErrNoShardForHashKey is returned when no open shard in a stream covers a hash key.
+var ErrNoShardForHashKey = errors.New("kinesis: no open shard covers the hash key")
+
+// HashKey returns the 128-bit hash key Kinesis uses to place a partition key. It is the MD5 of the key read as a big-endian unsigned integer.
+func HashKey(partitionKey string) *big.Int {
+	sum := md5.Sum([]byte(partitionKey))
+	return new(big.Int).SetBytes(sum[:])
+}
+
+// parseHashKey parses a decimal hash key string as found in a HashKeyRange.
+func parseHashKey(s string) (*big.Int, error) {
+	key, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("kinesis: invalid hash key %q", s)
+	}
+	return key, nil
+}
+
+// HashKeyBounds parses the shard's HashKeyRange and returns its starting and ending hash keys.
+func (s *Shard) HashKeyBounds() (*big.Int, *big.Int, error) {
+	start, err := parseHashKey(s.HashKeyRange.StartingHashKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	end, err := parseHashKey(s.HashKeyRange.EndingHashKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return start, end, nil
+}
+
+// Contains reports whether hashKey falls within the shard's HashKeyRange. A shard with an unparseable range contains nothing.
+func (s *Shard) Contains(hashKey *big.Int) bool {
+	start, end, err := s.HashKeyBounds()
+	if err != nil {
+		return false
+	}
+	return hashKey.Cmp(start) >= 0 && hashKey.Cmp(end) <= 0
+}
+
+// IsOpen reports whether the shard can still receive records. Shards closed by a split or merge have an EndingSequenceNumber.
+func (s *Shard) IsOpen() bool {
+	return s.SequenceNumberRange.EndingSequenceNumber == ""
+}
+
+// ShardForHashKey returns the open shard in shards whose HashKeyRange contains hashKey.
+func ShardForHashKey(shards []Shard, hashKey *big.Int) (Shard, error) {
+	for _, shard := range shards {
+		if shard.IsOpen() && shard.Contains(hashKey) {
+			return shard, nil
+		}
+	}
+	return Shard{}, ErrNoShardForHashKey
+}
+
+// ShardForPartitionKey describes the stream and returns the open shard that a record with partitionKey will be put on.
+func (s *Stream) ShardForPartitionKey(partitionKey string) (Shard, error) {
+	shards, err := s.Shards()
+	if err != nil {
+		return Shard{}, err
+	}
+	return ShardForHashKey(shards, HashKey(partitionKey))
+}
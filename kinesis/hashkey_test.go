@@ -0,0 +1,85 @@
+package kinesis
+
+import (
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHashKey(t *testing.T) {
+	Convey("When I hash the partition key \"a\"", t, func() {
+		result := HashKey("a")
+		Convey("The result is the MD5 of the key as an integer", func() {
+			So(result.String(), ShouldEqual, "16955237001963240173058271559858726497")
+		})
+		Convey("The result is within the hash key space", func() {
+			So(result.Cmp(MaxHashKey), ShouldBeLessThanOrEqualTo, 0)
+		})
+	})
+}
+
+func TestShardContains(t *testing.T) {
+	Convey("Given a shard covering the hash keys 10 through 20", t, func() {
+		shard := Shard{ShardId: "TestShard"}
+		shard.HashKeyRange.StartingHashKey = "10"
+		shard.HashKeyRange.EndingHashKey = "20"
+
+		Convey("It contains both ends of the range", func() {
+			So(shard.Contains(big.NewInt(10)), ShouldBeTrue)
+			So(shard.Contains(big.NewInt(20)), ShouldBeTrue)
+		})
+		Convey("It does not contain keys outside the range", func() {
+			So(shard.Contains(big.NewInt(9)), ShouldBeFalse)
+			So(shard.Contains(big.NewInt(21)), ShouldBeFalse)
+		})
+	})
+	Convey("Given a shard with a hash key range that is not a number", t, func() {
+		shard := Shard{ShardId: "TestShard"}
+		shard.HashKeyRange.StartingHashKey = "foo"
+		shard.HashKeyRange.EndingHashKey = "20"
+
+		Convey("HashKeyBounds returns an error", func() {
+			_, _, err := shard.HashKeyBounds()
+			So(err, ShouldNotBeNil)
+		})
+		Convey("It contains nothing", func() {
+			So(shard.Contains(big.NewInt(15)), ShouldBeFalse)
+		})
+	})
+}
+
+func TestShardForPartitionKey(t *testing.T) {
+	Convey("Given a stream with one closed and two open shards", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(testDescribeStreamSuccess))
+		ks := KinesisService{Endpoint: ts.URL}
+		testStream := Stream{Name: "foo", Service: &ks}
+
+		Convey("A key that hashes into an open shard is placed on that shard", func() {
+			shard, err := testStream.ShardForPartitionKey("partitionKey")
+			So(err, ShouldBeNil)
+			So(shard.ShardId, ShouldEqual, "shardId-000000000001")
+		})
+		Convey("A key that hashes into the last shard is placed on that shard", func() {
+			shard, err := testStream.ShardForPartitionKey("foo")
+			So(err, ShouldBeNil)
+			So(shard.ShardId, ShouldEqual, "shardId-000000000002")
+		})
+		Convey("A key that hashes into a closed shard returns an error", func() {
+			_, err := testStream.ShardForPartitionKey("a")
+			So(err, ShouldEqual, ErrNoShardForHashKey)
+		})
+	})
+	Convey("Given a stream with an endpoint that returns errors", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(testHTTP404))
+		ks := KinesisService{Endpoint: ts.URL}
+		testStream := Stream{Name: "foo", Service: &ks}
+
+		Convey("ShardForPartitionKey returns an error", func() {
+			_, err := testStream.ShardForPartitionKey("foo")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
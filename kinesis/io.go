@@ -0,0 +1,318 @@
+package kinesis
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Kinesis limits on the size of what is put.
+const (
+	MaxRecordBytes     = 1 << 20 // The largest record, data and partition key together.
+	MaxPutRecordsBytes = 5 << 20 // The most data one PutRecords call can carry.
+)
+
+// ErrFrameTooLarge is returned by a Writer when a message is larger than a record can hold.
+var ErrFrameTooLarge = errors.New("kinesis: message is larger than MaxRecordBytes")
+
+// ErrCorruptFrame is returned by a Writer with LengthPrefixedFraming when a length prefix is not a valid uvarint.
+// The Writer cannot find the next message after one, so every later Write returns it too.
+var ErrCorruptFrame = errors.New("kinesis: length prefix is not a valid uvarint")
+
+// Framing is how a Writer splits what is written into records, and how a Reader joins records back together.
+type Framing int
+
+const (
+	LineFraming           Framing = iota // Each line is a record, without its newline. A Reader ends each record with a newline.
+	LengthPrefixedFraming                // Each message is prefixed with its length as a uvarint, as ProtoCodec does.
+	NoFraming                            // Each call to Write is a record. A Reader concatenates records as they are.
+)
+
+// Writer is an io.Writer that puts what is written on a stream, split into records by Framing and sent in batches
+// with PutRecords. Call Flush to send a partial batch and Close when done. A Writer is not safe for concurrent use.
+type Writer struct {
+	Stream    *Stream
+	Framing   Framing
+	Keyer     PartitionKeyer // Chooses each record's partition key. Defaults to the stream's Keyer, or RandomKeyer if it has none.
+	BatchSize int            // The most records to send in one PutRecords call. Defaults to MaxPutRecordsEntries.
+
+	// PartitionKey chooses each record's partition key.
+	//
+	// Deprecated: Use Keyer. If PartitionKey is set it overrides Keyer.
+	PartitionKey func(data []byte) string
+
+	pending    []byte // Written data that is not yet a whole message.
+	discard    bool   // Whether to drop written data up to the next newline, the rest of a line that was too long.
+	skip       uint64 // How many written bytes to drop, the rest of a length-prefixed message that was too long.
+	corrupt    bool   // Whether a length prefix was corrupt.
+	batch      []PutRecordsEntry
+	batchBytes int
+}
+
+// NewWriter returns a Writer that puts each line written to it as a record on stream.
+func NewWriter(stream *Stream) *Writer {
+	return &Writer{Stream: stream}
+}
+
+// Write splits p into records, sending a batch whenever one fills up. Incomplete messages are kept until the rest is written.
+// A message larger than a record, or one the Keyer fails on, is dropped and its error returned; writing carries on
+// with the next message. If sending a batch fails, the records that were not sent are kept and sent again by the next
+// Write, Flush or Close.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.Framing == NoFraming {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		if added, err := w.add(w.keyer(), append([]byte(nil), p...)); !added {
+			return 0, err
+		} else if err != nil {
+			return len(p), err
+		}
+		return len(p), nil
+	}
+	if w.corrupt {
+		return 0, ErrCorruptFrame
+	}
+
+	w.pending = append(w.pending, p...)
+	w.drop()
+	keyer := w.keyer()
+	for {
+		message, rest, ok, err := w.nextMessage()
+		if err == ErrCorruptFrame {
+			w.pending = nil
+			w.corrupt = true
+			return len(p), err
+		}
+		if err != nil {
+			w.pending = rest
+			w.drop()
+			return len(p), err
+		}
+		if !ok {
+			break
+		}
+		added, err := w.add(keyer, message)
+		if added {
+			w.pending = rest
+		}
+		if err != nil {
+			return len(p), err
+		}
+	}
+
+	if w.Framing == LineFraming && len(w.pending) > MaxRecordBytes {
+		w.pending = nil
+		w.discard = true
+		return len(p), ErrFrameTooLarge
+	}
+	return len(p), nil
+}
+
+// drop drops the pending data that belongs to a message that was too long.
+func (w *Writer) drop() {
+	if w.skip > 0 {
+		n := uint64(len(w.pending))
+		if n > w.skip {
+			n = w.skip
+		}
+		w.pending = w.pending[n:]
+		w.skip -= n
+	}
+	if w.discard {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			w.pending = nil
+			return
+		}
+		w.pending = w.pending[i+1:]
+		w.discard = false
+	}
+}
+
+// nextMessage takes the first whole message off the pending data. ok is false if there is no whole message yet.
+// If the message is too large, rest starts after its length prefix and the length of its body is left to skip.
+func (w *Writer) nextMessage() (message []byte, rest []byte, ok bool, err error) {
+	if w.Framing == LengthPrefixedFraming {
+		length, n := binary.Uvarint(w.pending)
+		if n == 0 {
+			return nil, w.pending, false, nil
+		}
+		if n < 0 {
+			return nil, w.pending, false, ErrCorruptFrame
+		}
+		if length > MaxRecordBytes {
+			w.skip = length
+			return nil, w.pending[n:], false, ErrFrameTooLarge
+		}
+		end := n + int(length)
+		if len(w.pending) < end {
+			return nil, w.pending, false, nil
+		}
+		return append([]byte(nil), w.pending[n:end]...), w.pending[end:], true, nil
+	}
+
+	i := bytes.IndexByte(w.pending, '\n')
+	if i < 0 {
+		return nil, w.pending, false, nil
+	}
+	return append([]byte(nil), w.pending[:i]...), w.pending[i+1:], true, nil
+}
+
+// keyer returns the PartitionKeyer that chooses the partition keys of the records written.
+func (w *Writer) keyer() PartitionKeyer {
+	switch {
+	case w.PartitionKey != nil:
+		return partitionKeyFunc(w.PartitionKey)
+	case w.Keyer != nil:
+		return w.Keyer
+	case w.Stream.Keyer != nil:
+		return w.Stream.Keyer
+	}
+	return RandomKeyer{}
+}
+
+// add adds a record to the batch, sending the batch first if the record would not fit.
+// added is false if the record is still to be added, because sending the batch failed first.
+// A record that is too large to send or that the Keyer fails on counts as added, since it is dropped.
+func (w *Writer) add(keyer PartitionKeyer, data []byte) (added bool, err error) {
+	partitionKey, explicitHashKey, err := keyer.PartitionKey(data)
+	if err != nil {
+		return true, err
+	}
+	entry := PutRecordsEntry{Data: data, ExplicitHashKey: explicitHashKey, PartitionKey: partitionKey}
+	size := entrySize(entry)
+	if size > MaxRecordBytes {
+		return true, ErrFrameTooLarge
+	}
+
+	batchSize := w.BatchSize
+	if batchSize <= 0 || batchSize > MaxPutRecordsEntries {
+		batchSize = MaxPutRecordsEntries
+	}
+	if w.batchBytes+size > MaxPutRecordsBytes || len(w.batch) >= batchSize {
+		if err := w.Flush(); err != nil {
+			return false, err
+		}
+	}
+
+	w.batch = append(w.batch, entry)
+	w.batchBytes += size
+	if len(w.batch) >= batchSize {
+		return true, w.Flush()
+	}
+	return true, nil
+}
+
+// partitionKeyFunc adapts a Writer's PartitionKey func to a PartitionKeyer.
+type partitionKeyFunc func(data []byte) string
+
+func (f partitionKeyFunc) PartitionKey(data []byte) (string, string, error) {
+	return f(data), "", nil
+}
+
+// Flush sends the records in the current batch. Data that is not yet a whole message is kept.
+// If some records cannot be sent, they stay in the batch to be sent by the next Flush.
+func (w *Writer) Flush() error {
+	if len(w.batch) == 0 {
+		return nil
+	}
+	unsent, err := w.Stream.putUnsent(w.batch)
+	w.batch = unsent
+	w.batchBytes = 0
+	for _, entry := range unsent {
+		w.batchBytes += entrySize(entry)
+	}
+	return err
+}
+
+// Unsent returns the number of records in the current batch, such as those that a failed Flush could not send.
+func (w *Writer) Unsent() int {
+	return len(w.batch)
+}
+
+// Close sends whatever is left. With LineFraming a last line without a newline is sent as a record;
+// with LengthPrefixedFraming an incomplete message is an io.ErrUnexpectedEOF.
+func (w *Writer) Close() error {
+	if w.Framing != NoFraming {
+		if _, err := w.Write(nil); err != nil {
+			return err
+		}
+	}
+	if len(w.pending) > 0 {
+		if w.Framing == LengthPrefixedFraming {
+			return io.ErrUnexpectedEOF
+		}
+		added, err := w.add(w.keyer(), w.pending)
+		if added {
+			w.pending = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// Reader is an io.Reader over the records a ShardReader reads, joined together by Framing.
+// It returns io.EOF when the ShardReader closes its record channel, for example when StopAtLatest is set.
+type Reader struct {
+	Framing Framing
+
+	shardReader *ShardReader
+	records     <-chan Record
+	errc        <-chan error
+	pending     []byte
+}
+
+// NewReader returns a Reader that ends each record from r with a newline. r is started by the first Read.
+func NewReader(r *ShardReader) *Reader {
+	return &Reader{shardReader: r}
+}
+
+// Read reads the data of the next records into p.
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.records == nil {
+		r.records, r.errc = r.shardReader.Start()
+	}
+
+	for len(r.pending) == 0 {
+		select {
+		case record, ok := <-r.records:
+			if !ok {
+				return 0, io.EOF
+			}
+			data, err := record.Bytes()
+			if err != nil {
+				return 0, err
+			}
+			r.pending = r.frame(data)
+		case err := <-r.errc:
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// frame adds the framing around one record's data.
+func (r *Reader) frame(data []byte) []byte {
+	switch r.Framing {
+	case LineFraming:
+		return append(data, '\n')
+	case LengthPrefixedFraming:
+		prefix := make([]byte, binary.MaxVarintLen64)
+		n := binary.PutUvarint(prefix, uint64(len(data)))
+		return append(prefix[:n], data...)
+	}
+	return data
+}
+
+// Close stops the ShardReader.
+func (r *Reader) Close() error {
+	r.shardReader.Stop()
+	return nil
+}
@@ -0,0 +1,145 @@
+package kinesis
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/controlgroup/gaws/kinesis/kinesistest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWriterAndReader(t *testing.T) {
+	Convey("Given a stream with one shard", t, func() {
+		server := kinesistest.NewServer()
+		defer server.Close()
+		ks := KinesisService{Endpoint: server.URL}
+		stream, _ := ks.CreateStream("foo", 1)
+		shards, _ := stream.Shards()
+
+		readAll := func(framing Framing) []byte {
+			reader := NewReader(&ShardReader{Shard: &shards[0], StopAtLatest: true})
+			reader.Framing = framing
+			defer reader.Close()
+			data, err := ioutil.ReadAll(reader)
+			So(err, ShouldBeNil)
+			return data
+		}
+
+		Convey("Lines copied to a Writer can be read back from a Reader", func() {
+			w := NewWriter(&stream)
+			w.BatchSize = 2
+			_, err := io.Copy(w, strings.NewReader("one\ntwo\nthree\nfour"))
+			So(err, ShouldBeNil)
+			So(server.Requests("PutRecords"), ShouldEqual, 1)
+			So(w.Close(), ShouldBeNil)
+			So(server.Requests("PutRecords"), ShouldEqual, 2)
+
+			So(string(readAll(LineFraming)), ShouldEqual, "one\ntwo\nthree\nfour\n")
+		})
+		Convey("A line split across writes is one record", func() {
+			w := NewWriter(&stream)
+			w.Write([]byte("hel"))
+			w.Write([]byte("lo\n"))
+			So(w.Flush(), ShouldBeNil)
+
+			So(string(readAll(NoFraming)), ShouldEqual, "hello")
+		})
+		Convey("Length-prefixed messages keep their boundaries", func() {
+			messages := &bytes.Buffer{}
+			prefix := make([]byte, binary.MaxVarintLen64)
+			for _, message := range []string{"a\nb", "", "c"} {
+				n := binary.PutUvarint(prefix, uint64(len(message)))
+				messages.Write(prefix[:n])
+				messages.WriteString(message)
+			}
+
+			w := &Writer{Stream: &stream, Framing: LengthPrefixedFraming, Keyer: FixedKeyer{Key: "key"}}
+			w.Write(messages.Bytes()[:3])
+			w.Write(messages.Bytes()[3:])
+			So(w.Close(), ShouldBeNil)
+
+			So(readAll(LengthPrefixedFraming), ShouldResemble, messages.Bytes())
+		})
+		Convey("A PartitionKey func overrides the Keyer", func() {
+			w := &Writer{Stream: &stream, Keyer: FixedKeyer{Key: "keyer"}}
+			w.PartitionKey = func(data []byte) string { return "func" }
+			w.Write([]byte("one\n"))
+			So(w.Close(), ShouldBeNil)
+
+			reader := &ShardReader{Shard: &shards[0], StopAtLatest: true}
+			records, _ := reader.Start()
+			keys := []string{}
+			for record := range records {
+				keys = append(keys, record.PartitionKey)
+			}
+			So(keys, ShouldResemble, []string{"func"})
+		})
+		Convey("An incomplete length-prefixed message is an error on Close", func() {
+			w := &Writer{Stream: &stream, Framing: LengthPrefixedFraming}
+			w.Write([]byte{5, 'a'})
+			So(w.Close(), ShouldEqual, io.ErrUnexpectedEOF)
+		})
+		Convey("A line longer than a record is an error", func() {
+			w := NewWriter(&stream)
+			_, err := w.Write(bytes.Repeat([]byte("x"), MaxRecordBytes+1))
+			So(err, ShouldEqual, ErrFrameTooLarge)
+
+			Convey("And the rest of it is dropped, so later lines are still written", func() {
+				_, err := w.Write([]byte("xxx\nnext\n"))
+				So(err, ShouldBeNil)
+				So(len(w.pending), ShouldEqual, 0)
+				So(w.Close(), ShouldBeNil)
+
+				So(string(readAll(LineFraming)), ShouldEqual, "next\n")
+			})
+		})
+		Convey("A length-prefixed message longer than a record is skipped", func() {
+			w := &Writer{Stream: &stream, Framing: LengthPrefixedFraming}
+			prefix := make([]byte, binary.MaxVarintLen64)
+			n := binary.PutUvarint(prefix, MaxRecordBytes+1)
+			_, err := w.Write(prefix[:n])
+			So(err, ShouldEqual, ErrFrameTooLarge)
+
+			body := bytes.Repeat([]byte("x"), MaxRecordBytes+1)
+			_, err = w.Write(body[:MaxRecordBytes/2])
+			So(err, ShouldBeNil)
+			_, err = w.Write(append(body[MaxRecordBytes/2:], 2, 'o', 'k'))
+			So(err, ShouldBeNil)
+			So(w.Close(), ShouldBeNil)
+
+			So(readAll(LengthPrefixedFraming), ShouldResemble, []byte{2, 'o', 'k'})
+		})
+		Convey("A corrupt length prefix stops the Writer", func() {
+			w := &Writer{Stream: &stream, Framing: LengthPrefixedFraming}
+			_, err := w.Write(bytes.Repeat([]byte{0xff}, binary.MaxVarintLen64+1))
+			So(err, ShouldEqual, ErrCorruptFrame)
+			_, err = w.Write([]byte{2, 'o', 'k'})
+			So(err, ShouldEqual, ErrCorruptFrame)
+		})
+		Convey("A message the Keyer fails on is dropped and writing carries on", func() {
+			w := &Writer{Stream: &stream, Keyer: FieldKeyer{Field: "user"}}
+			_, err := w.Write([]byte(`{"action": "login"}` + "\n"))
+			So(err, ShouldEqual, ErrMissingField)
+			_, err = w.Write([]byte(`{"user": "alice"}` + "\n"))
+			So(err, ShouldBeNil)
+			So(w.Close(), ShouldBeNil)
+
+			So(string(readAll(LineFraming)), ShouldEqual, `{"user": "alice"}`+"\n")
+		})
+		Convey("Records a failed flush did not send are sent by the next one", func() {
+			w := NewWriter(&stream)
+			w.BatchSize = 2
+			server.InjectFaults("PutRecords", kinesistest.Fault{Status: 400, Type: "ValidationException"})
+			_, err := w.Write([]byte("one\ntwo\nthree\n"))
+			So(err, ShouldNotBeNil)
+
+			So(w.Flush(), ShouldBeNil)
+			So(w.Close(), ShouldBeNil)
+			So(string(readAll(LineFraming)), ShouldEqual, "one\ntwo\nthree\n")
+		})
+	})
+}
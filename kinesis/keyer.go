@@ -0,0 +1,132 @@
+package kinesis
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PartitionKeyer chooses where a record is put. It returns the partition key and, optionally, an explicit hash key
+// that places the record on a shard in place of the hash of the partition key.
+// A Stream uses its Keyer for records that are put without a partition key.
+type PartitionKeyer interface {
+	PartitionKey(data []byte) (partitionKey string, explicitHashKey string, err error)
+}
+
+// ErrMissingField is returned by a FieldKeyer when a record does not have the field.
+var ErrMissingField = errors.New("kinesis: record does not have the partition key field")
+
+// RandomKeyer gives every record a random partition key, which spreads records evenly over the shards
+// but does not keep any of them in order.
+type RandomKeyer struct{}
+
+// PartitionKey returns a random partition key.
+func (RandomKeyer) PartitionKey(data []byte) (string, string, error) {
+	return strconv.FormatInt(rand.Int63(), 36), "", nil
+}
+
+// FixedKeyer puts every record with the same partition key, so every record goes to one shard in order.
+type FixedKeyer struct {
+	Key string
+}
+
+// PartitionKey returns Key.
+func (k FixedKeyer) PartitionKey(data []byte) (string, string, error) {
+	return k.Key, "", nil
+}
+
+// FieldKeyer uses a field of each record as its partition key, so records with the same value, such as the same
+// user ID, go to the same shard in order. Records are decoded into a map, so Codec must be able to decode into one.
+type FieldKeyer struct {
+	Field string
+	Codec Codec // Decodes records. Defaults to DefaultCodec.
+}
+
+// PartitionKey returns the value of the field, formatted with fmt.Sprint. JSON numbers are kept as they were written,
+// so large numbers that would round to the same float64 still give different keys.
+func (k FieldKeyer) PartitionKey(data []byte) (string, string, error) {
+	codec := k.Codec
+	if codec == nil {
+		codec = DefaultCodec
+	}
+
+	fields := map[string]interface{}{}
+	switch codec.(type) {
+	case JSONCodec, *JSONCodec:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&fields); err != nil {
+			return "", "", err
+		}
+	default:
+		if err := codec.Unmarshal(data, &fields); err != nil {
+			return "", "", err
+		}
+	}
+	value, ok := fields[k.Field]
+	if !ok || value == nil {
+		return "", "", ErrMissingField
+	}
+	key := fmt.Sprint(value)
+	if key == "" {
+		return "", "", ErrMissingField
+	}
+	return key, "", nil
+}
+
+// RoundRobinKeyer puts records on each open shard in turn by giving them the shard's starting hash key as their
+// explicit hash key. It spreads records evenly even when partition keys would not, for example with few distinct keys.
+// It is safe for concurrent use.
+type RoundRobinKeyer struct {
+	Stream          *Stream
+	RefreshInterval time.Duration // How often to describe the stream again to pick up resharding. Defaults to one minute.
+
+	mu        sync.Mutex
+	shards    []Shard
+	refreshed time.Time
+	next      int
+}
+
+// PartitionKey returns the ID of the next open shard as the partition key and its starting hash key as the explicit hash key.
+func (k *RoundRobinKeyer) PartitionKey(data []byte) (string, string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	interval := k.RefreshInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	if len(k.shards) == 0 || time.Since(k.refreshed) >= interval {
+		shards, err := k.Stream.OpenShards()
+		if err != nil {
+			return "", "", err
+		}
+		if len(shards) == 0 {
+			return "", "", ErrNoShardForHashKey
+		}
+		k.shards = shards
+		k.refreshed = time.Now()
+	}
+
+	shard := k.shards[k.next%len(k.shards)]
+	k.next = (k.next + 1) % len(k.shards)
+	return shard.ShardId, shard.HashKeyRange.StartingHashKey, nil
+}
+
+// assignKey returns the partition key and explicit hash key to put data with. Records that already have a partition
+// key keep it; the others are given one by the stream's Keyer, if it has one.
+func (s *Stream) assignKey(partitionKey string, explicitHashKey string, data []byte) (string, string, error) {
+	if partitionKey != "" || s.Keyer == nil {
+		return partitionKey, explicitHashKey, nil
+	}
+	key, hashKey, err := s.Keyer.PartitionKey(data)
+	if explicitHashKey != "" {
+		hashKey = explicitHashKey
+	}
+	return key, hashKey, err
+}
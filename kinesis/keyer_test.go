@@ -0,0 +1,113 @@
+package kinesis
+
+import (
+	"testing"
+
+	"github.com/controlgroup/gaws/kinesis/kinesistest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// recordsPerShard dumps stream and counts its records by shard.
+func recordsPerShard(stream *Stream) map[string]int {
+	w := &sliceArchiveWriter{}
+	stream.Dump(w, DumpOptions{})
+	counts := map[string]int{}
+	for _, record := range w.records {
+		counts[record.ShardId]++
+	}
+	return counts
+}
+
+func TestPartitionKeyers(t *testing.T) {
+	Convey("RandomKeyer gives records different keys", t, func() {
+		first, hashKey, err := RandomKeyer{}.PartitionKey(nil)
+		So(err, ShouldBeNil)
+		So(hashKey, ShouldEqual, "")
+		second, _, _ := RandomKeyer{}.PartitionKey(nil)
+		So(first, ShouldNotEqual, second)
+	})
+	Convey("FixedKeyer gives every record its key", t, func() {
+		key, _, _ := FixedKeyer{Key: "a"}.PartitionKey([]byte("one"))
+		So(key, ShouldEqual, "a")
+	})
+	Convey("Given a FieldKeyer for the user field", t, func() {
+		keyer := FieldKeyer{Field: "user"}
+
+		Convey("The field's value is the key", func() {
+			key, _, err := keyer.PartitionKey([]byte(`{"user": "alice", "action": "login"}`))
+			So(err, ShouldBeNil)
+			So(key, ShouldEqual, "alice")
+			key, _, _ = keyer.PartitionKey([]byte(`{"user": 42}`))
+			So(key, ShouldEqual, "42")
+		})
+		Convey("Large numbers keep every digit", func() {
+			key, _, err := keyer.PartitionKey([]byte(`{"user": 9007199254740993}`))
+			So(err, ShouldBeNil)
+			So(key, ShouldEqual, "9007199254740993")
+			other, _, _ := keyer.PartitionKey([]byte(`{"user": 9007199254740992}`))
+			So(other, ShouldNotEqual, key)
+		})
+		Convey("Large numbers keep every digit with a *JSONCodec too", func() {
+			keyer.Codec = &JSONCodec{}
+			key, _, err := keyer.PartitionKey([]byte(`{"user": 9007199254740993}`))
+			So(err, ShouldBeNil)
+			So(key, ShouldEqual, "9007199254740993")
+		})
+		Convey("A record without the field is an error", func() {
+			_, _, err := keyer.PartitionKey([]byte(`{"action": "login"}`))
+			So(err, ShouldEqual, ErrMissingField)
+		})
+		Convey("A record that cannot be decoded is an error", func() {
+			_, _, err := keyer.PartitionKey([]byte(`not JSON`))
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given a stream with three shards and a round-robin keyer", t, func() {
+		server := kinesistest.NewServer()
+		defer server.Close()
+		ks := KinesisService{Endpoint: server.URL}
+		stream, _ := ks.CreateStream("foo", 3)
+		stream.Keyer = &RoundRobinKeyer{Stream: &stream}
+
+		Convey("PutRecord without a key spreads records evenly", func() {
+			for i := 0; i < 6; i++ {
+				So(stream.PutRecord("", []byte("x")), ShouldBeNil)
+			}
+			counts := recordsPerShard(&stream)
+			So(len(counts), ShouldEqual, 3)
+			for _, count := range counts {
+				So(count, ShouldEqual, 2)
+			}
+		})
+		Convey("PutRecords keeps the keys it is given", func() {
+			entries := []PutRecordsEntry{{Data: []byte("x")}, {Data: []byte("x")}, {PartitionKey: "a", Data: []byte("x")}}
+			output, err := stream.PutRecords(entries)
+			So(err, ShouldBeNil)
+			So(output.Records[0].ShardId, ShouldNotEqual, output.Records[1].ShardId)
+
+			shard, _ := stream.ShardForPartitionKey("a")
+			So(output.Records[2].ShardId, ShouldEqual, shard.ShardId)
+		})
+		Convey("A producer with a limiter keys records before waiting on their shard", func() {
+			producer := Producer{Stream: &stream, Limiter: &ShardLimiter{Stream: &stream}}
+			for i := 0; i < 3; i++ {
+				So(producer.Put("", []byte("x")), ShouldBeNil)
+			}
+			n, err := producer.PutRecords([]PutRecordsEntry{{Data: []byte("x")}, {Data: []byte("x")}, {Data: []byte("x")}})
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 3)
+
+			for _, count := range recordsPerShard(&stream) {
+				So(count, ShouldEqual, 2)
+			}
+		})
+	})
+	Convey("A stream without a Keyer puts records with the key it is given", t, func() {
+		stream := Stream{Name: "foo"}
+		key, hashKey, err := stream.assignKey("", "", []byte("x"))
+		So(err, ShouldBeNil)
+		So(key, ShouldEqual, "")
+		So(hashKey, ShouldEqual, "")
+	})
+}
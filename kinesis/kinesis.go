@@ -19,6 +19,12 @@ func (e kinesisError) Error() string {
 	return fmt.Sprintf("%v: %v", e.Type, e.Message)
 }
 
+// isErrorType reports whether err is a kinesisError of the given type, such as ExpiredIteratorException.
+func isErrorType(err error, errorType string) bool {
+	e, ok := err.(kinesisError)
+	return ok && e.Type == errorType
+}
+
 func kinesisRetryPredicate(status int, body []byte) (bool, error) {
 	if status < 400 {
 		return false, nil
@@ -62,9 +68,10 @@ func (s *KinesisService) request() gaws.AWSRequest {
 
 // putRecordRequest is a Kinesis record. These are put onto Streams.
 type putRecordRequest struct {
-	StreamName   string
-	Data         string
-	PartitionKey string
+	StreamName      string
+	Data            string
+	ExplicitHashKey string `json:",omitempty"`
+	PartitionKey    string
 }
 
 // KinesisService is the Kinesis service at AWS.
@@ -74,8 +81,11 @@ type KinesisService struct {
 
 // Stream is a Kinesis stream
 type Stream struct {
-	Name    string          // The name of the stream
-	Service *KinesisService // The service for this region
+	Name       string          // The name of the stream
+	Service    *KinesisService // The service for this region
+	Codec      Codec           // The codec PutValue uses. If it is nil, DefaultCodec is used.
+	Compressor Compressor      // Compresses data put on the stream. If it is nil, data is put as is.
+	Keyer      PartitionKeyer  // Chooses partition keys for records put without one. If it is nil, a partition key is required.
 }
 
 // createStreamRequest is the request to the CreateStream API call.
@@ -145,24 +155,27 @@ type getRecordsRequest struct {
 	ShardIterator string // The shard iterator to use.
 }
 
-// Record is a Kinesis record returned in a GetRecordsResponse.
+// Record is a Kinesis record returned in a GetRecordsOutput.
 type Record struct {
-	Data           string // The data blob. It is Base64 encoded.
-	PartitionKey   string // Identifies which shard in the stream the data record is assigned to.
-	SequenceNumber string // The unique identifier for the record in the Amazon Kinesis stream.
+	ApproximateArrivalTimestamp float64 // When the record was put on the stream, in seconds since the epoch.
+	Data                        string  // The data blob. It is Base64 encoded.
+	EncryptionType              string  // NONE or KMS.
+	PartitionKey                string  // Identifies which shard in the stream the data record is assigned to.
+	SequenceNumber              string  // The unique identifier for the record in the Amazon Kinesis stream.
 }
 
-// getRecordsResponse is returned by GetRecords.
-type getRecordsResponse struct {
-	NextShardIterator string   // The next position in the shard from which to start sequentially reading data records.
-	Records           []Record // A slice of Record structs
+// GetRecordsOutput is returned by GetRecords.
+type GetRecordsOutput struct {
+	MillisBehindLatest int64    // How far the records are behind the tip of the stream, in milliseconds. 0 means the reader is caught up.
+	NextShardIterator  string   // The next position in the shard from which to start sequentially reading data records. It is empty once a closed shard has been read.
+	Records            []Record // A slice of Record structs
 }
 
 // GetRecords returns one or more data records from a stream. limit can be an integer up to 10,000. If it is 0, this will use the default limit.
 // See http://docs.aws.amazon.com/kinesis/latest/APIReference/API_GetRecords.html for more details.
-func (s *KinesisService) GetRecords(shardIterator string, limit int) ([]Record, string, error) {
+func (s *KinesisService) GetRecords(shardIterator string, limit int) (GetRecordsOutput, error) {
 	request := getRecordsRequest{ShardIterator: shardIterator, Limit: limit}
-	result := getRecordsResponse{}
+	result := GetRecordsOutput{}
 
 	req := s.request()
 
@@ -173,31 +186,32 @@ func (s *KinesisService) GetRecords(shardIterator string, limit int) ([]Record,
 
 	resp, err := req.Do()
 	if err != nil {
-		return []Record{}, "", err
+		return GetRecordsOutput{}, err
 	}
 
 	err = json.Unmarshal(resp, &result)
 
-	return result.Records, result.NextShardIterator, err
+	return result, err
 
 }
 
 // BUG(drocamor): StreamRecords is a terrible name.
 
 // StreamRecords creates a goroutine and uses GetRecords to send records over a channel. If it encounters an error, it will send the error over the error channel and exit the goroutine.
+// It cannot renew an expired shard iterator; use a ShardReader to read a shard for longer than that.
 func (s *KinesisService) StreamRecords(shardIterator string) (<-chan Record, <-chan error) {
 	c := make(chan Record)
 	errc := make(chan error)
 	go func() {
 		for {
-			records, newiterator, err := s.GetRecords(shardIterator, 0)
+			output, err := s.GetRecords(shardIterator, 0)
 
 			if err != nil {
 				errc <- err
 				break
 			}
-			shardIterator = newiterator
-			for _, r := range records {
+			shardIterator = output.NextShardIterator
+			for _, r := range output.Records {
 				c <- r
 			}
 		}
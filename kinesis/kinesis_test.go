@@ -108,10 +108,13 @@ func TestListStreams(t *testing.T) {
 }
 
 var testGetRecordsResult []byte = []byte(`{
+  "MillisBehindLatest": 2100,
   "NextShardIterator": "AAAAAAAAAAHsW8zCWf9164uy8Epue6WS3w6wmj4a4USt+CNvMd6uXQ+HL5vAJMznqqC0DLKsIjuoiTi1BpT6nW0LN2M2D56zM5H8anHm30Gbri9ua+qaGgj+3XTyvbhpERfrezgLHbPB/rIcVpykJbaSj5tmcXYRmFnqZBEyHwtZYFmh6hvWVFkIwLuMZLMrpWhG5r5hzkE=",
   "Records": [
     {
+      "ApproximateArrivalTimestamp": 1441215410.867,
       "Data": "XzxkYXRhPl8w",
+      "EncryptionType": "NONE",
       "PartitionKey": "partitionKey",
       "SequenceNumber": "21269319989652663814458848515492872193"
     }
@@ -129,7 +132,8 @@ func TestGetRecords(t *testing.T) {
 		ts := httptest.NewServer(http.HandlerFunc(testGetRecordsSuccess))
 		ks := KinesisService{Endpoint: ts.URL}
 
-		records, nextIterator, err := ks.GetRecords("foo", 0)
+		output, err := ks.GetRecords("foo", 0)
+		records, nextIterator := output.Records, output.NextShardIterator
 
 		Convey("It should not return an error", func() {
 			So(err, ShouldBeNil)
@@ -139,12 +143,21 @@ func TestGetRecords(t *testing.T) {
 			So(records[0].Data, ShouldEqual, "XzxkYXRhPl8w")
 			So(nextIterator, ShouldEqual, "AAAAAAAAAAHsW8zCWf9164uy8Epue6WS3w6wmj4a4USt+CNvMd6uXQ+HL5vAJMznqqC0DLKsIjuoiTi1BpT6nW0LN2M2D56zM5H8anHm30Gbri9ua+qaGgj+3XTyvbhpERfrezgLHbPB/rIcVpykJbaSj5tmcXYRmFnqZBEyHwtZYFmh6hvWVFkIwLuMZLMrpWhG5r5hzkE=")
 		})
+
+		Convey("It should return how far behind the stream the records are", func() {
+			So(output.MillisBehindLatest, ShouldEqual, 2100)
+		})
+
+		Convey("It should return the arrival time and encryption type of each record", func() {
+			So(records[0].ArrivalTime().Unix(), ShouldEqual, 1441215410)
+			So(records[0].EncryptionType, ShouldEqual, "NONE")
+		})
 	})
 	Convey("When you call stream.Describe() on a stream with an endpoint that returns errors", t, func() {
 		ts := httptest.NewServer(http.HandlerFunc(testHTTP404))
 		ks := KinesisService{Endpoint: ts.URL}
 
-		_, _, err := ks.GetRecords("foo", 0)
+		_, err := ks.GetRecords("foo", 0)
 		Convey("The result will return an error", func() {
 			So(err, ShouldNotBeNil)
 		})
@@ -153,7 +166,7 @@ func TestGetRecords(t *testing.T) {
 		ts := httptest.NewServer(http.HandlerFunc(testHTTP200))
 		ks := KinesisService{Endpoint: ts.URL}
 
-		_, _, err := ks.GetRecords("foo", 0)
+		_, err := ks.GetRecords("foo", 0)
 		Convey("It should return an error", func() {
 			So(err, ShouldNotBeNil)
 		})
@@ -0,0 +1,814 @@
+// Package kinesistest provides an in-memory Kinesis service for tests.
+//
+// A Server speaks the Kinesis JSON API over HTTP and keeps real state: streams, shards with hash key ranges,
+// sequence numbers, shard iterators, and stream status transitions. Point a kinesis.KinesisService at its URL:
+//
+//	server := kinesistest.NewServer()
+//	defer server.Close()
+//	ks := kinesis.KinesisService{Endpoint: server.URL}
+package kinesistest
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxHashKey is 2^128 - 1, the end of the hash key space.
+var maxHashKey = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+
+// Server is an in-memory Kinesis service. Its exported fields may be changed between requests.
+type Server struct {
+	URL string // The base URL of the server, for KinesisService.Endpoint.
+
+	// TransitionDelay is how long a stream stays CREATING, UPDATING or DELETING. 0 makes every change immediate.
+	TransitionDelay time.Duration
+
+	// IteratorTTL is how long a shard iterator stays valid. Defaults to five minutes, like Kinesis.
+	IteratorTTL time.Duration
+
+	// ShardLimit is the most open shards the account may have across every stream. Defaults to 500, like Kinesis.
+	ShardLimit int
+
+	server *httptest.Server
+
+	mu        sync.Mutex
+	streams   map[string]*stream
+	iterators map[string]*iterator
+	faults    map[string][]Fault
+	nextID    int
+	requests  map[string]int
+}
+
+type stream struct {
+	name           string
+	status         string
+	created        time.Time
+	settlesAt      time.Time // When the current status becomes the next one.
+	shards         []*shard
+	nextShardId    int
+	nextSequence   int64
+	retentionHours int
+}
+
+type shard struct {
+	id                    string
+	parentShardId         string
+	adjacentParentShardId string
+	startingHashKey       *big.Int
+	endingHashKey         *big.Int
+	startingSequence      string
+	endingSequence        string
+	records               []record
+}
+
+type record struct {
+	arrival        time.Time
+	data           string
+	partitionKey   string
+	sequenceNumber string
+}
+
+type iterator struct {
+	stream  string
+	shard   string
+	index   int
+	expires time.Time
+}
+
+// Fault is an error the server returns in place of handling a request.
+type Fault struct {
+	Status int    // The HTTP status code, such as 400 or 500.
+	Type   string // The __type of the error document, such as ProvisionedThroughputExceededException.
+}
+
+// NewServer starts a Server. Close it when the test is done.
+func NewServer() *Server {
+	s := &Server{
+		IteratorTTL: 5 * time.Minute,
+		ShardLimit:  500,
+		streams:     map[string]*stream{},
+		iterators:   map[string]*iterator{},
+		faults:      map[string][]Fault{},
+		requests:    map[string]int{},
+	}
+	s.server = httptest.NewServer(s)
+	s.URL = s.server.URL
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// InjectFaults makes the next calls to operation, such as "PutRecords", fail with faults in order.
+func (s *Server) InjectFaults(operation string, faults ...Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[operation] = append(s.faults[operation], faults...)
+}
+
+// Throttle makes the next n calls to operation fail with ProvisionedThroughputExceededException.
+func (s *Server) Throttle(operation string, n int) {
+	for i := 0; i < n; i++ {
+		s.InjectFaults(operation, Fault{Status: 400, Type: "ProvisionedThroughputExceededException"})
+	}
+}
+
+// Requests returns how many times operation has been called, including calls that failed.
+func (s *Server) Requests(operation string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[operation]
+}
+
+// ExpireIterators makes every shard iterator handed out so far expire.
+func (s *Server) ExpireIterators() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, it := range s.iterators {
+		it.expires = time.Time{}
+	}
+}
+
+// apiError is an error document returned to the client.
+type apiError struct {
+	status  int
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return e.Type + ": " + e.Message
+}
+
+func newError(status int, errorType string, format string, args ...interface{}) *apiError {
+	return &apiError{status: status, Type: errorType, Message: fmt.Sprintf(format, args...)}
+}
+
+// request holds the fields of every operation the server understands.
+type request struct {
+	AdjacentShardToMerge   string
+	Data                   string
+	ExclusiveStartShardId  string
+	ExplicitHashKey        string
+	Limit                  int
+	NewStartingHashKey     string
+	PartitionKey           string
+	Records                []request
+	ShardCount             int
+	ShardId                string
+	ShardIterator          string
+	ShardIteratorType      string
+	ShardToMerge           string
+	ShardToSplit           string
+	StartingSequenceNumber string
+	StreamName             string
+	TargetShardCount       int
+	Timestamp              float64
+}
+
+// ServeHTTP handles a Kinesis API call. The operation is taken from the X-Amz-Target header.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.Header.Get("X-Amz-Target")
+	operation := target[strings.LastIndex(target, ".")+1:]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests[operation]++
+	result, err := s.handle(operation, r)
+
+	if err == nil && result == nil {
+		result = struct{}{}
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	if err != nil {
+		body, _ := json.Marshal(err)
+		w.WriteHeader(err.status)
+		w.Write(body)
+		return
+	}
+	body, _ := json.Marshal(result)
+	w.Write(body)
+}
+
+func (s *Server) handle(operation string, r *http.Request) (interface{}, *apiError) {
+	if faults := s.faults[operation]; len(faults) > 0 {
+		s.faults[operation] = faults[1:]
+		return nil, newError(faults[0].Status, faults[0].Type, "injected fault")
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+	req := request{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, newError(400, "SerializationException", "%v", err)
+		}
+	}
+
+	now := time.Now()
+	for name, st := range s.streams {
+		s.settle(name, st, now)
+	}
+
+	switch operation {
+	case "CreateStream":
+		return s.createStream(req, now)
+	case "DeleteStream":
+		return s.deleteStream(req, now)
+	case "ListStreams":
+		return s.listStreams()
+	case "DescribeStream":
+		return s.describeStream(req)
+	case "DescribeStreamSummary":
+		return s.describeStreamSummary(req)
+	case "DescribeLimits":
+		return s.describeLimits()
+	case "PutRecord":
+		return s.putRecord(req, now)
+	case "PutRecords":
+		return s.putRecords(req, now)
+	case "GetShardIterator":
+		return s.getShardIterator(req, now)
+	case "GetRecords":
+		return s.getRecords(req, now)
+	case "SplitShard":
+		return s.splitShard(req, now)
+	case "MergeShards":
+		return s.mergeShards(req, now)
+	case "UpdateShardCount":
+		return s.updateShardCount(req, now)
+	}
+	return nil, newError(400, "UnknownOperationException", "unknown operation %q", operation)
+}
+
+// settle moves a stream on to its next status once its TransitionDelay has passed.
+func (s *Server) settle(name string, st *stream, now time.Time) {
+	if st.status == "ACTIVE" || now.Before(st.settlesAt) {
+		return
+	}
+	if st.status == "DELETING" {
+		delete(s.streams, name)
+		return
+	}
+	st.status = "ACTIVE"
+}
+
+// transition puts a stream into status until TransitionDelay passes.
+func (s *Server) transition(st *stream, status string, now time.Time) {
+	st.status = status
+	st.settlesAt = now.Add(s.TransitionDelay)
+	if s.TransitionDelay == 0 {
+		st.status = "ACTIVE"
+	}
+}
+
+func (s *Server) findStream(name string) (*stream, *apiError) {
+	st, ok := s.streams[name]
+	if !ok {
+		return nil, newError(400, "ResourceNotFoundException", "Stream %v not found", name)
+	}
+	return st, nil
+}
+
+func (s *Server) activeStream(name string) (*stream, *apiError) {
+	st, err := s.findStream(name)
+	if err != nil {
+		return nil, err
+	}
+	if st.status != "ACTIVE" {
+		return nil, newError(400, "ResourceInUseException", "Stream %v is %v", name, st.status)
+	}
+	return st, nil
+}
+
+func (st *stream) findShard(id string) (*shard, *apiError) {
+	for _, sh := range st.shards {
+		if sh.id == id {
+			return sh, nil
+		}
+	}
+	return nil, newError(400, "ResourceNotFoundException", "Shard %v in stream %v not found", id, st.name)
+}
+
+func (st *stream) openShards() []*shard {
+	open := []*shard{}
+	for _, sh := range st.shards {
+		if sh.endingSequence == "" {
+			open = append(open, sh)
+		}
+	}
+	return open
+}
+
+// sequenceNumber returns the next sequence number in the stream. They are zero padded so they sort as strings too.
+func (st *stream) sequenceNumber() string {
+	st.nextSequence++
+	return fmt.Sprintf("%056d", st.nextSequence)
+}
+
+// currentSequenceNumber is the last sequence number handed out.
+func (st *stream) currentSequenceNumber() string {
+	return fmt.Sprintf("%056d", st.nextSequence)
+}
+
+func (st *stream) addShard(start *big.Int, end *big.Int, parent string, adjacentParent string) *shard {
+	sh := &shard{
+		id:                    fmt.Sprintf("shardId-%012d", st.nextShardId),
+		parentShardId:         parent,
+		adjacentParentShardId: adjacentParent,
+		startingHashKey:       start,
+		endingHashKey:         end,
+		startingSequence:      st.sequenceNumber(),
+	}
+	st.nextShardId++
+	st.shards = append(st.shards, sh)
+	return sh
+}
+
+func (s *Server) createStream(req request, now time.Time) (interface{}, *apiError) {
+	if _, ok := s.streams[req.StreamName]; ok {
+		return nil, newError(400, "ResourceInUseException", "Stream %v already exists", req.StreamName)
+	}
+	if req.ShardCount < 1 {
+		return nil, newError(400, "InvalidArgumentException", "ShardCount must be at least 1")
+	}
+	if err := s.checkShardLimit(req.ShardCount); err != nil {
+		return nil, err
+	}
+
+	st := &stream{name: req.StreamName, created: now, retentionHours: 24}
+	width := new(big.Int).Div(new(big.Int).Add(maxHashKey, big.NewInt(1)), big.NewInt(int64(req.ShardCount)))
+	for i := 0; i < req.ShardCount; i++ {
+		start := new(big.Int).Mul(width, big.NewInt(int64(i)))
+		end := new(big.Int).Sub(new(big.Int).Add(start, width), big.NewInt(1))
+		if i == req.ShardCount-1 {
+			end = new(big.Int).Set(maxHashKey)
+		}
+		st.addShard(start, end, "", "")
+	}
+
+	s.streams[req.StreamName] = st
+	s.transition(st, "CREATING", now)
+	return nil, nil
+}
+
+func (s *Server) deleteStream(req request, now time.Time) (interface{}, *apiError) {
+	st, err := s.findStream(req.StreamName)
+	if err != nil {
+		return nil, err
+	}
+	if s.TransitionDelay == 0 {
+		delete(s.streams, req.StreamName)
+		return nil, nil
+	}
+	st.status = "DELETING"
+	st.settlesAt = now.Add(s.TransitionDelay)
+	return nil, nil
+}
+
+func (s *Server) listStreams() (interface{}, *apiError) {
+	names := []string{}
+	for name := range s.streams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return map[string]interface{}{"HasMoreStreams": false, "StreamNames": names}, nil
+}
+
+type hashKeyRange struct {
+	EndingHashKey   string
+	StartingHashKey string
+}
+
+type sequenceNumberRange struct {
+	EndingSequenceNumber   string `json:",omitempty"`
+	StartingSequenceNumber string
+}
+
+type shardDescription struct {
+	AdjacentParentShardId string `json:",omitempty"`
+	HashKeyRange          hashKeyRange
+	ParentShardId         string `json:",omitempty"`
+	SequenceNumberRange   sequenceNumberRange
+	ShardId               string
+}
+
+func (sh *shard) describe() shardDescription {
+	return shardDescription{
+		AdjacentParentShardId: sh.adjacentParentShardId,
+		HashKeyRange:          hashKeyRange{StartingHashKey: sh.startingHashKey.String(), EndingHashKey: sh.endingHashKey.String()},
+		ParentShardId:         sh.parentShardId,
+		SequenceNumberRange:   sequenceNumberRange{StartingSequenceNumber: sh.startingSequence, EndingSequenceNumber: sh.endingSequence},
+		ShardId:               sh.id,
+	}
+}
+
+func (s *Server) describeStream(req request) (interface{}, *apiError) {
+	st, err := s.findStream(req.StreamName)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := req.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
+
+	shards := []shardDescription{}
+	started := req.ExclusiveStartShardId == ""
+	hasMore := false
+	for _, sh := range st.shards {
+		if !started {
+			started = sh.id == req.ExclusiveStartShardId
+			continue
+		}
+		if len(shards) == limit {
+			hasMore = true
+			break
+		}
+		shards = append(shards, sh.describe())
+	}
+
+	return map[string]interface{}{"StreamDescription": map[string]interface{}{
+		"HasMoreShards": hasMore,
+		"Shards":        shards,
+		"StreamARN":     "arn:aws:kinesis:us-east-1:000000000000:stream/" + st.name,
+		"StreamName":    st.name,
+		"StreamStatus":  st.status,
+	}}, nil
+}
+
+func (s *Server) describeStreamSummary(req request) (interface{}, *apiError) {
+	st, err := s.findStream(req.StreamName)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{"StreamDescriptionSummary": map[string]interface{}{
+		"ConsumerCount":           0,
+		"EncryptionType":          "NONE",
+		"EnhancedMonitoring":      []interface{}{map[string]interface{}{"ShardLevelMetrics": []string{}}},
+		"OpenShardCount":          len(st.openShards()),
+		"RetentionPeriodHours":    st.retentionHours,
+		"StreamARN":               "arn:aws:kinesis:us-east-1:000000000000:stream/" + st.name,
+		"StreamCreationTimestamp": float64(st.created.UnixNano()) / float64(time.Second),
+		"StreamName":              st.name,
+		"StreamStatus":            st.status,
+	}}, nil
+}
+
+// openShardCount is the number of open shards in every stream.
+func (s *Server) openShardCount() int {
+	count := 0
+	for _, st := range s.streams {
+		count += len(st.openShards())
+	}
+	return count
+}
+
+// checkShardLimit returns a LimitExceededException if opening more shards would go over ShardLimit.
+func (s *Server) checkShardLimit(more int) *apiError {
+	if s.ShardLimit > 0 && s.openShardCount()+more > s.ShardLimit {
+		return newError(400, "LimitExceededException", "opening %v more shards would exceed the shard limit of %v", more, s.ShardLimit)
+	}
+	return nil
+}
+
+func (s *Server) describeLimits() (interface{}, *apiError) {
+	return map[string]interface{}{"OpenShardCount": s.openShardCount(), "ShardLimit": s.ShardLimit}, nil
+}
+
+// hashKey returns the hash key for a record: ExplicitHashKey if it is set, otherwise the MD5 of the partition key.
+func hashKey(partitionKey string, explicitHashKey string) (*big.Int, *apiError) {
+	if partitionKey == "" {
+		return nil, newError(400, "ValidationException", "PartitionKey is required")
+	}
+	if explicitHashKey != "" {
+		key, ok := new(big.Int).SetString(explicitHashKey, 10)
+		if !ok || key.Sign() < 0 || key.Cmp(maxHashKey) > 0 {
+			return nil, newError(400, "InvalidArgumentException", "ExplicitHashKey %v is not a valid hash key", explicitHashKey)
+		}
+		return key, nil
+	}
+	sum := md5.Sum([]byte(partitionKey))
+	return new(big.Int).SetBytes(sum[:]), nil
+}
+
+// put adds a record to the open shard that covers its hash key.
+func (st *stream) put(entry request, now time.Time) (map[string]string, *apiError) {
+	if _, err := base64.StdEncoding.DecodeString(entry.Data); err != nil {
+		return nil, newError(400, "SerializationException", "Data is not Base64 encoded")
+	}
+	key, err := hashKey(entry.PartitionKey, entry.ExplicitHashKey)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sh := range st.openShards() {
+		if key.Cmp(sh.startingHashKey) >= 0 && key.Cmp(sh.endingHashKey) <= 0 {
+			r := record{arrival: now, data: entry.Data, partitionKey: entry.PartitionKey, sequenceNumber: st.sequenceNumber()}
+			sh.records = append(sh.records, r)
+			return map[string]string{"SequenceNumber": r.sequenceNumber, "ShardId": sh.id}, nil
+		}
+	}
+	return nil, newError(500, "InternalFailure", "no open shard for hash key %v", key)
+}
+
+// Kinesis limits on the size of what is put. A record's size is its data and partition key together.
+const (
+	maxRecordBytes     = 1 << 20
+	maxPutRecordsBytes = 5 << 20
+)
+
+// recordSize returns the size of a record, or a ValidationException if it is larger than Kinesis accepts.
+func recordSize(entry request) (int, *apiError) {
+	data, err := base64.StdEncoding.DecodeString(entry.Data)
+	if err != nil {
+		return 0, newError(400, "SerializationException", "Data is not Base64 encoded")
+	}
+	size := len(data) + len(entry.PartitionKey)
+	if size > maxRecordBytes {
+		return 0, newError(400, "ValidationException", "Record of %v bytes is larger than %v bytes", size, maxRecordBytes)
+	}
+	return size, nil
+}
+
+func (s *Server) putRecord(req request, now time.Time) (interface{}, *apiError) {
+	st, err := s.writableStream(req.StreamName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := recordSize(req); err != nil {
+		return nil, err
+	}
+	return st.put(req, now)
+}
+
+func (s *Server) putRecords(req request, now time.Time) (interface{}, *apiError) {
+	st, err := s.writableStream(req.StreamName)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Records) == 0 || len(req.Records) > 500 {
+		return nil, newError(400, "InvalidArgumentException", "PutRecords takes 1 to 500 records, got %v", len(req.Records))
+	}
+	total := 0
+	for _, entry := range req.Records {
+		size, err := recordSize(entry)
+		if err != nil {
+			return nil, err
+		}
+		total += size
+	}
+	if total > maxPutRecordsBytes {
+		return nil, newError(400, "InvalidArgumentException", "PutRecords of %v bytes is larger than %v bytes", total, maxPutRecordsBytes)
+	}
+
+	failed := 0
+	results := []map[string]string{}
+	for _, entry := range req.Records {
+		result, err := st.put(entry, now)
+		if err != nil {
+			failed++
+			result = map[string]string{"ErrorCode": err.Type, "ErrorMessage": err.Message}
+		}
+		results = append(results, result)
+	}
+	return map[string]interface{}{"FailedRecordCount": failed, "Records": results}, nil
+}
+
+// writableStream returns a stream that can take records. Kinesis accepts records while a stream is UPDATING.
+func (s *Server) writableStream(name string) (*stream, *apiError) {
+	st, err := s.findStream(name)
+	if err != nil {
+		return nil, err
+	}
+	if st.status != "ACTIVE" && st.status != "UPDATING" {
+		return nil, newError(400, "ResourceNotFoundException", "Stream %v is %v", name, st.status)
+	}
+	return st, nil
+}
+
+func (s *Server) newIterator(streamName string, shardId string, index int, now time.Time) string {
+	s.nextID++
+	id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%v/%v/%v", streamName, shardId, s.nextID)))
+	s.iterators[id] = &iterator{stream: streamName, shard: shardId, index: index, expires: now.Add(s.IteratorTTL)}
+	return id
+}
+
+func (s *Server) getShardIterator(req request, now time.Time) (interface{}, *apiError) {
+	st, err := s.writableStream(req.StreamName)
+	if err != nil {
+		return nil, err
+	}
+	sh, err := st.findShard(req.ShardId)
+	if err != nil {
+		return nil, err
+	}
+
+	index := -1
+	switch req.ShardIteratorType {
+	case "TRIM_HORIZON":
+		index = 0
+	case "LATEST":
+		index = len(sh.records)
+	case "AT_SEQUENCE_NUMBER", "AFTER_SEQUENCE_NUMBER":
+		for i, r := range sh.records {
+			if r.sequenceNumber >= padSequenceNumber(req.StartingSequenceNumber) {
+				index = i
+				if req.ShardIteratorType == "AFTER_SEQUENCE_NUMBER" && r.sequenceNumber == padSequenceNumber(req.StartingSequenceNumber) {
+					index++
+				}
+				break
+			}
+		}
+		if index == -1 {
+			index = len(sh.records)
+		}
+	case "AT_TIMESTAMP":
+		seconds := int64(req.Timestamp)
+		at := time.Unix(seconds, int64((req.Timestamp-float64(seconds))*float64(time.Second)))
+		index = len(sh.records)
+		for i, r := range sh.records {
+			if !r.arrival.Before(at) {
+				index = i
+				break
+			}
+		}
+	default:
+		return nil, newError(400, "InvalidArgumentException", "unknown ShardIteratorType %q", req.ShardIteratorType)
+	}
+
+	return map[string]string{"ShardIterator": s.newIterator(st.name, sh.id, index, now)}, nil
+}
+
+// padSequenceNumber pads a sequence number to the width the server hands out, so they compare as strings.
+func padSequenceNumber(sequenceNumber string) string {
+	if len(sequenceNumber) >= 56 {
+		return sequenceNumber
+	}
+	return strings.Repeat("0", 56-len(sequenceNumber)) + sequenceNumber
+}
+
+type recordOutput struct {
+	ApproximateArrivalTimestamp float64
+	Data                        string
+	EncryptionType              string
+	PartitionKey                string
+	SequenceNumber              string
+}
+
+func (s *Server) getRecords(req request, now time.Time) (interface{}, *apiError) {
+	it, ok := s.iterators[req.ShardIterator]
+	if !ok {
+		return nil, newError(400, "InvalidArgumentException", "invalid shard iterator")
+	}
+	if now.After(it.expires) {
+		return nil, newError(400, "ExpiredIteratorException", "Iterator expired")
+	}
+
+	st, err := s.findStream(it.stream)
+	if err != nil {
+		return nil, err
+	}
+	sh, err := st.findShard(it.shard)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := req.Limit
+	if limit <= 0 || limit > 10000 {
+		limit = 10000
+	}
+	end := it.index + limit
+	if end > len(sh.records) {
+		end = len(sh.records)
+	}
+
+	records := []recordOutput{}
+	for _, r := range sh.records[it.index:end] {
+		records = append(records, recordOutput{
+			ApproximateArrivalTimestamp: float64(r.arrival.UnixNano()) / float64(time.Second),
+			Data:                        r.data,
+			EncryptionType:              "NONE",
+			PartitionKey:                r.partitionKey,
+			SequenceNumber:              r.sequenceNumber,
+		})
+	}
+
+	var millisBehind int64
+	if end < len(sh.records) {
+		millisBehind = int64(now.Sub(sh.records[end].arrival) / time.Millisecond)
+	}
+
+	result := map[string]interface{}{"MillisBehindLatest": millisBehind, "Records": records}
+	if sh.endingSequence == "" || end < len(sh.records) {
+		result["NextShardIterator"] = s.newIterator(st.name, sh.id, end, now)
+	}
+	return result, nil
+}
+
+func (s *Server) splitShard(req request, now time.Time) (interface{}, *apiError) {
+	st, err := s.activeStream(req.StreamName)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := st.findShard(req.ShardToSplit)
+	if err != nil {
+		return nil, err
+	}
+	if parent.endingSequence != "" {
+		return nil, newError(400, "ResourceInUseException", "Shard %v is closed", parent.id)
+	}
+
+	newStart, ok := new(big.Int).SetString(req.NewStartingHashKey, 10)
+	if !ok || newStart.Cmp(parent.startingHashKey) <= 0 || newStart.Cmp(parent.endingHashKey) > 0 {
+		return nil, newError(400, "InvalidArgumentException", "NewStartingHashKey %v is not inside shard %v", req.NewStartingHashKey, parent.id)
+	}
+
+	if err := s.checkShardLimit(1); err != nil {
+		return nil, err
+	}
+
+	parent.endingSequence = st.currentSequenceNumber()
+	st.addShard(parent.startingHashKey, new(big.Int).Sub(newStart, big.NewInt(1)), parent.id, "")
+	st.addShard(newStart, parent.endingHashKey, parent.id, "")
+	s.transition(st, "UPDATING", now)
+	return nil, nil
+}
+
+func (s *Server) mergeShards(req request, now time.Time) (interface{}, *apiError) {
+	st, err := s.activeStream(req.StreamName)
+	if err != nil {
+		return nil, err
+	}
+	lower, err := st.findShard(req.ShardToMerge)
+	if err != nil {
+		return nil, err
+	}
+	upper, err := st.findShard(req.AdjacentShardToMerge)
+	if err != nil {
+		return nil, err
+	}
+	if lower.endingSequence != "" || upper.endingSequence != "" {
+		return nil, newError(400, "ResourceInUseException", "Shards %v and %v must both be open", lower.id, upper.id)
+	}
+	if upper.startingHashKey.Cmp(lower.startingHashKey) < 0 {
+		lower, upper = upper, lower
+	}
+	if new(big.Int).Add(lower.endingHashKey, big.NewInt(1)).Cmp(upper.startingHashKey) != 0 {
+		return nil, newError(400, "InvalidArgumentException", "Shards %v and %v are not adjacent", lower.id, upper.id)
+	}
+
+	ending := st.currentSequenceNumber()
+	lower.endingSequence = ending
+	upper.endingSequence = ending
+	st.addShard(lower.startingHashKey, upper.endingHashKey, req.ShardToMerge, req.AdjacentShardToMerge)
+	s.transition(st, "UPDATING", now)
+	return nil, nil
+}
+
+func (s *Server) updateShardCount(req request, now time.Time) (interface{}, *apiError) {
+	st, err := s.activeStream(req.StreamName)
+	if err != nil {
+		return nil, err
+	}
+	current := len(st.openShards())
+	if req.TargetShardCount < 1 || req.TargetShardCount > 2*current || 2*req.TargetShardCount < current {
+		return nil, newError(400, "InvalidArgumentException", "TargetShardCount %v must be between half and double the %v open shards", req.TargetShardCount, current)
+	}
+	if err := s.checkShardLimit(req.TargetShardCount - current); err != nil {
+		return nil, err
+	}
+
+	ending := st.currentSequenceNumber()
+	for _, sh := range st.openShards() {
+		sh.endingSequence = ending
+	}
+
+	width := new(big.Int).Div(new(big.Int).Add(maxHashKey, big.NewInt(1)), big.NewInt(int64(req.TargetShardCount)))
+	for i := 0; i < req.TargetShardCount; i++ {
+		start := new(big.Int).Mul(width, big.NewInt(int64(i)))
+		end := new(big.Int).Sub(new(big.Int).Add(start, width), big.NewInt(1))
+		if i == req.TargetShardCount-1 {
+			end = new(big.Int).Set(maxHashKey)
+		}
+		st.addShard(start, end, "", "")
+	}
+
+	s.transition(st, "UPDATING", now)
+	return map[string]interface{}{"CurrentShardCount": current, "StreamName": st.name, "TargetShardCount": req.TargetShardCount}, nil
+}
@@ -0,0 +1,167 @@
+package kinesistest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/controlgroup/gaws/kinesis"
+	"github.com/controlgroup/gaws/kinesis/kinesistest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStreamLifecycle(t *testing.T) {
+	Convey("Given a server that takes a moment to create streams", t, func() {
+		server := kinesistest.NewServer()
+		defer server.Close()
+		server.TransitionDelay = 20 * time.Millisecond
+		ks := kinesis.KinesisService{Endpoint: server.URL}
+
+		stream, err := ks.CreateStream("foo", 2)
+		So(err, ShouldBeNil)
+
+		Convey("The stream is CREATING, then ACTIVE", func() {
+			description, err := stream.Describe()
+			So(err, ShouldBeNil)
+			So(description.StreamStatus, ShouldEqual, "CREATING")
+
+			time.Sleep(30 * time.Millisecond)
+			description, _ = stream.Describe()
+			So(description.StreamStatus, ShouldEqual, "ACTIVE")
+		})
+		Convey("Its shards cover the whole hash key space", func() {
+			description, _ := stream.Describe()
+			So(len(description.Shards), ShouldEqual, 2)
+			So(description.Shards[0].HashKeyRange.StartingHashKey, ShouldEqual, "0")
+			So(description.Shards[1].HashKeyRange.EndingHashKey, ShouldEqual, kinesis.MaxHashKey.String())
+		})
+		Convey("It is listed", func() {
+			streams, err := ks.ListStreams()
+			So(err, ShouldBeNil)
+			So(len(streams), ShouldEqual, 1)
+			So(streams[0].Name, ShouldEqual, "foo")
+		})
+		Convey("Creating it again fails", func() {
+			_, err := ks.CreateStream("foo", 2)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestRecords(t *testing.T) {
+	Convey("Given an ACTIVE stream with one shard", t, func() {
+		server := kinesistest.NewServer()
+		defer server.Close()
+		ks := kinesis.KinesisService{Endpoint: server.URL}
+		stream, _ := ks.CreateStream("foo", 1)
+
+		So(stream.PutRecord("a", []byte("one")), ShouldBeNil)
+		So(stream.PutRecord("b", []byte("two")), ShouldBeNil)
+
+		shards, err := stream.OpenShards()
+		So(err, ShouldBeNil)
+
+		Convey("Records are read back in order from TRIM_HORIZON", func() {
+			reader := &kinesis.ShardReader{Shard: &shards[0], StopAtLatest: true}
+			c, _ := reader.Start()
+
+			data := []string{}
+			for record := range c {
+				b, _ := record.Bytes()
+				data = append(data, string(b))
+			}
+			So(data, ShouldResemble, []string{"one", "two"})
+		})
+		Convey("An expired iterator is reported as ExpiredIteratorException", func() {
+			iterator, _ := shards[0].GetShardIterator(kinesis.TrimHorizon())
+			server.ExpireIterators()
+			_, err := ks.GetRecords(iterator, 0)
+			So(err.Error(), ShouldStartWith, "ExpiredIteratorException")
+		})
+		Convey("AFTER_SEQUENCE_NUMBER skips the named record", func() {
+			iterator, _ := shards[0].GetShardIterator(kinesis.TrimHorizon())
+			output, _ := ks.GetRecords(iterator, 1)
+
+			iterator, err := shards[0].GetShardIterator(kinesis.AfterSequence(output.Records[0].SequenceNumber))
+			So(err, ShouldBeNil)
+			output, _ = ks.GetRecords(iterator, 0)
+			So(len(output.Records), ShouldEqual, 1)
+			So(output.Records[0].PartitionKey, ShouldEqual, "b")
+		})
+		Convey("A record larger than 1 MiB is rejected", func() {
+			err := stream.PutRecord("c", make([]byte, 1<<20))
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "ValidationException")
+
+			_, err = stream.PutRecords([]kinesis.PutRecordsEntry{{PartitionKey: "c", Data: make([]byte, 1<<20)}})
+			So(err.Error(), ShouldStartWith, "ValidationException")
+		})
+		Convey("A PutRecords call larger than 5 MiB is rejected", func() {
+			entries := []kinesis.PutRecordsEntry{}
+			for i := 0; i < 7; i++ {
+				entries = append(entries, kinesis.PutRecordsEntry{PartitionKey: "c", Data: make([]byte, 768<<10)})
+			}
+			_, err := stream.PutRecords(entries)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "InvalidArgumentException")
+			So(server.Requests("PutRecords"), ShouldEqual, 1)
+		})
+		Convey("Throttled puts are retried by the client", func() {
+			server.Throttle("PutRecord", 1)
+			So(stream.PutRecord("c", []byte("three")), ShouldBeNil)
+			So(server.Requests("PutRecord"), ShouldEqual, 4)
+		})
+	})
+}
+
+func TestResharding(t *testing.T) {
+	Convey("Given an ACTIVE stream with one shard holding a record", t, func() {
+		server := kinesistest.NewServer()
+		defer server.Close()
+		ks := kinesis.KinesisService{Endpoint: server.URL}
+		stream, _ := ks.CreateStream("foo", 1)
+		stream.PutRecord("a", []byte("one"))
+
+		Convey("Splitting it evenly closes it and opens two children", func() {
+			shards, _ := stream.OpenShards()
+			So(shards[0].SplitEvenly(), ShouldBeNil)
+
+			all, _ := stream.Shards()
+			So(len(all), ShouldEqual, 3)
+			So(all[0].IsOpen(), ShouldBeFalse)
+			So(all[1].ParentShardId, ShouldEqual, all[0].ShardId)
+			So(all[2].HashKeyRange.EndingHashKey, ShouldEqual, kinesis.MaxHashKey.String())
+
+			Convey("The closed parent can still be read to its end", func() {
+				reader := &kinesis.ShardReader{Shard: &all[0]}
+				c, _ := reader.Start()
+				count := 0
+				for range c {
+					count++
+				}
+				So(count, ShouldEqual, 1)
+			})
+			Convey("Merging the children leaves one open shard", func() {
+				So(stream.MergeShards(all[1].ShardId, all[2].ShardId), ShouldBeNil)
+				open, _ := stream.OpenShards()
+				So(len(open), ShouldEqual, 1)
+				So(open[0].AdjacentParentShardId, ShouldEqual, all[2].ShardId)
+			})
+		})
+		Convey("Resharding to five shards leaves five open shards", func() {
+			So(stream.Reshard(5), ShouldBeNil)
+			open, _ := stream.OpenShards()
+			So(len(open), ShouldEqual, 5)
+
+			summary, _ := stream.DescribeSummary()
+			So(summary.OpenShardCount, ShouldEqual, 5)
+		})
+		Convey("Splitting past the shard limit fails", func() {
+			server.ShardLimit = 1
+			shards, _ := stream.OpenShards()
+			So(shards[0].SplitEvenly(), ShouldNotBeNil)
+
+			limits, _ := ks.DescribeLimits()
+			So(limits.OpenShardCount, ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,71 @@
+package kinesis
+
+import (
+	"github.com/controlgroup/gaws"
+)
+
+// The metrics below are reported to gaws.DefaultMetrics, labeled by stream and, for reads, by shard.
+// Put bytes are counted after compression, as Kinesis counts them.
+
+// recordPut reports a record put with PutRecord. size is the size of its data after compression.
+func recordPut(stream *Stream, size int) {
+	labels := gaws.Labels{"stream": stream.Name}
+	metrics := gaws.DefaultMetrics()
+	metrics.Add("kinesis_records_put_total", labels, 1)
+	metrics.Add("kinesis_put_bytes_total", labels, float64(size))
+}
+
+// recordPutBatch reports a PutRecords call and how many of its records failed or were throttled.
+// sizes are the sizes of the records' data after compression, in the order they were put.
+func recordPutBatch(stream *Stream, sizes []int, output PutRecordsOutput) {
+	labels := gaws.Labels{"stream": stream.Name}
+	metrics := gaws.DefaultMetrics()
+
+	size := 0
+	throttled := 0
+	for i, result := range output.Records {
+		if result.ErrorCode == "ProvisionedThroughputExceededException" {
+			throttled++
+		}
+		if result.ErrorCode == "" && i < len(sizes) {
+			size += sizes[i]
+		}
+	}
+
+	metrics.Observe("kinesis_put_batch_size", labels, float64(len(sizes)))
+	metrics.Add("kinesis_records_put_total", labels, float64(len(sizes)-output.FailedRecordCount))
+	metrics.Add("kinesis_records_put_failed_total", labels, float64(output.FailedRecordCount))
+	metrics.Add("kinesis_records_put_throttled_total", labels, float64(throttled))
+	metrics.Add("kinesis_put_bytes_total", labels, float64(size))
+}
+
+// recordPutThrottle reports n records whose put was refused with ProvisionedThroughputExceededException as a whole,
+// rather than record by record in a PutRecords result.
+func recordPutThrottle(stream *Stream, n int) {
+	gaws.DefaultMetrics().Add("kinesis_records_put_throttled_total", gaws.Labels{"stream": stream.Name}, float64(n))
+}
+
+// shardLabels labels a metric with a shard and its stream.
+func shardLabels(shard *Shard) gaws.Labels {
+	labels := gaws.Labels{"shard": shard.ShardId}
+	if shard.stream != nil {
+		labels["stream"] = shard.stream.Name
+	}
+	return labels
+}
+
+// recordRead reports a GetRecords call made by a ShardReader.
+func recordRead(shard *Shard, output GetRecordsOutput) {
+	labels := shardLabels(shard)
+	metrics := gaws.DefaultMetrics()
+
+	metrics.Add("kinesis_records_read_total", labels, float64(len(output.Records)))
+	metrics.Add("kinesis_read_bytes_total", labels, float64(recordBytes(output.Records)))
+	metrics.Observe("kinesis_get_records_batch_size", labels, float64(len(output.Records)))
+	metrics.Set("kinesis_iterator_age_milliseconds", labels, float64(output.MillisBehindLatest))
+}
+
+// recordReadThrottle reports a GetRecords call that was throttled.
+func recordReadThrottle(shard *Shard) {
+	gaws.DefaultMetrics().Add("kinesis_read_throttles_total", shardLabels(shard), 1)
+}
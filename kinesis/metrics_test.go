@@ -0,0 +1,56 @@
+package kinesis
+
+import (
+	"testing"
+
+	"github.com/controlgroup/gaws"
+	"github.com/controlgroup/gaws/kinesis/kinesistest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestKinesisMetrics(t *testing.T) {
+	Convey("Given DefaultMetrics is a registry and a stream with one shard", t, func() {
+		registry := gaws.NewRegistry()
+		gaws.SetDefaultMetrics(registry)
+		defer gaws.SetDefaultMetrics(nil)
+
+		server := kinesistest.NewServer()
+		defer server.Close()
+		ks := KinesisService{Endpoint: server.URL}
+		stream, _ := ks.CreateStream("foo", 1)
+
+		stream.PutRecord("a", []byte("one"))
+		stream.PutRecords([]PutRecordsEntry{{PartitionKey: "a", Data: []byte("two")}, {PartitionKey: "b", Data: []byte("three")}})
+
+		Convey("Puts are counted", func() {
+			labels := gaws.Labels{"stream": "foo"}
+			So(registry.Value("kinesis_records_put_total", labels), ShouldEqual, 3)
+			So(registry.Value("kinesis_put_bytes_total", labels), ShouldEqual, 11)
+			So(registry.Value("kinesis_put_batch_size", labels), ShouldEqual, 1)
+		})
+		Convey("Reads are counted by shard", func() {
+			shards, _ := stream.Shards()
+			reader := &ShardReader{Shard: &shards[0], StopAtLatest: true}
+			records, _ := reader.Start()
+			defer reader.Stop()
+			for range records {
+			}
+
+			labels := gaws.Labels{"stream": "foo", "shard": shards[0].ShardId}
+			So(registry.Value("kinesis_records_read_total", labels), ShouldEqual, 3)
+			So(registry.Value("kinesis_iterator_age_milliseconds", labels), ShouldEqual, 0)
+		})
+		Convey("Put bytes are counted after compression by PutRecord and PutRecords alike", func() {
+			compressed, _ := ks.CreateStream("compressed", 1)
+			compressed.Compressor = GzipCompressor{}
+			labels := gaws.Labels{"stream": "compressed"}
+			data := make([]byte, 1000)
+			wire, _ := Compress(compressed.Compressor, data)
+
+			compressed.PutRecord("a", data)
+			So(registry.Value("kinesis_put_bytes_total", labels), ShouldEqual, len(wire))
+			compressed.PutRecords([]PutRecordsEntry{{PartitionKey: "a", Data: data}})
+			So(registry.Value("kinesis_put_bytes_total", labels), ShouldEqual, 2*len(wire))
+		})
+	})
+}
@@ -0,0 +1,306 @@
+package kinesis
+
+import (
+	"encoding/base64"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/controlgroup/gaws"
+)
+
+// Mirror copies every record from one stream to another, which may belong to another KinesisService in another region
+// or account. Records keep their partition keys and data; the destination's Compressor is not applied again.
+// Each shard is copied in order, and the children of a split or merge are only copied once their parents are done,
+// so records with the same partition key arrive in the order they were put. When some records of a batch fail,
+// the first failed record and every record after it are put again, so a record may also arrive once too early as a duplicate.
+type Mirror struct {
+	Source       *Stream
+	Destination  *Stream
+	Checkpointer Checkpointer  // Saves progress after each batch is put. Defaults to a MemoryCheckpointer.
+	Position     StartPosition // Where to start shards that have no checkpoint. Defaults to TrimHorizon.
+	Governor     *ReadGovernor // Optional. Spaces out reads from the source.
+
+	BatchSize       int           // The most records to put in one call. Defaults to MaxPutRecordsEntries.
+	FlushInterval   time.Duration // The longest a record waits for its batch to fill. Defaults to one second.
+	RefreshInterval time.Duration // How often to look for new shards in the source. Defaults to one minute.
+
+	mu  sync.Mutex
+	lag map[string]int64 // MillisBehindLatest by shard ID.
+}
+
+func (m *Mirror) checkpointer() Checkpointer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Checkpointer == nil {
+		m.Checkpointer = &MemoryCheckpointer{}
+	}
+	return m.Checkpointer
+}
+
+// Lag returns how far the slowest shard is behind the tip of the source stream.
+func (m *Mirror) Lag() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var most int64
+	for _, millis := range m.lag {
+		if millis > most {
+			most = millis
+		}
+	}
+	return time.Duration(most) * time.Millisecond
+}
+
+// ShardLag returns how far each shard being copied is behind the tip of the source stream.
+func (m *Mirror) ShardLag() map[string]time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := map[string]time.Duration{}
+	for shardId, millis := range m.lag {
+		result[shardId] = time.Duration(millis) * time.Millisecond
+	}
+	return result
+}
+
+func (m *Mirror) setLag(shardId string, millis int64, done bool) {
+	m.mu.Lock()
+	if m.lag == nil {
+		m.lag = map[string]int64{}
+	}
+	if done {
+		delete(m.lag, shardId)
+	} else {
+		m.lag[shardId] = millis
+	}
+	m.mu.Unlock()
+
+	gaws.DefaultMetrics().Set("kinesis_mirror_lag_milliseconds", gaws.Labels{"stream": m.Source.Name, "shard": shardId}, float64(millis))
+}
+
+// shardReady reports whether a shard's parents have been copied. Parents that are no longer in the stream
+// have passed the retention period, so there is nothing left to copy from them.
+func shardReady(shard Shard, present map[string]bool, finished map[string]bool) bool {
+	for _, parent := range []string{shard.ParentShardId, shard.AdjacentParentShardId} {
+		if parent != "" && present[parent] && !finished[parent] {
+			return false
+		}
+	}
+	return true
+}
+
+// mirrorResult is sent by a shard's goroutine when it ends.
+type mirrorResult struct {
+	shardId string
+	err     error
+}
+
+// Run copies records until stop is closed or an error occurs. It looks for new shards every RefreshInterval and starts
+// copying each one once its parents are done. It returns nil once stop is closed, after every shard has put and
+// checkpointed the records it had read.
+func (m *Mirror) Run(stop <-chan struct{}) error {
+	checkpointer := m.checkpointer()
+	refreshInterval := m.RefreshInterval
+	if refreshInterval <= 0 {
+		refreshInterval = time.Minute
+	}
+
+	running := map[string]bool{}
+	finished := map[string]bool{}
+	results := make(chan mirrorResult)
+	stopShards := make(chan struct{})
+
+	startShards := func() error {
+		shards, err := m.Source.Shards()
+		if err != nil {
+			return err
+		}
+		present := map[string]bool{}
+		for _, shard := range shards {
+			present[shard.ShardId] = true
+		}
+
+		for i := range shards {
+			shard := &shards[i]
+			if running[shard.ShardId] || finished[shard.ShardId] {
+				continue
+			}
+			checkpoint, err := checkpointer.LastCheckpoint(shard.ShardId)
+			if err != nil {
+				return err
+			}
+			if checkpoint == ShardEnd {
+				finished[shard.ShardId] = true
+				continue
+			}
+		}
+
+		for i := range shards {
+			shard := &shards[i]
+			if running[shard.ShardId] || finished[shard.ShardId] || !shardReady(*shard, present, finished) {
+				continue
+			}
+			running[shard.ShardId] = true
+			go func() {
+				results <- mirrorResult{shard.ShardId, m.mirrorShard(shard, checkpointer, stopShards)}
+			}()
+		}
+		return nil
+	}
+
+	refresh := time.NewTicker(refreshInterval)
+	defer refresh.Stop()
+
+	err := startShards()
+loop:
+	for err == nil {
+		select {
+		case result := <-results:
+			delete(running, result.shardId)
+			err = result.err
+			if err == nil {
+				finished[result.shardId] = true
+				err = startShards()
+			}
+		case <-refresh.C:
+			err = startShards()
+		case <-stop:
+			break loop
+		}
+	}
+
+	// Stop the other shards and wait for them to put and checkpoint what they have read.
+	close(stopShards)
+	for len(running) > 0 {
+		result := <-results
+		delete(running, result.shardId)
+		if err == nil {
+			err = result.err
+		}
+	}
+	return err
+}
+
+// mirrorShard copies one shard in batches, checkpointing after each batch is put. It returns nil when a closed shard
+// has been copied completely or stop is closed.
+func (m *Mirror) mirrorShard(shard *Shard, checkpointer Checkpointer, stop <-chan struct{}) error {
+	checkpoint, err := checkpointer.LastCheckpoint(shard.ShardId)
+	if err != nil {
+		return err
+	}
+	position := m.Position
+	if position.iteratorType == "" {
+		position = TrimHorizon()
+	}
+	reader := &ShardReader{Shard: shard, Position: checkpointPosition(checkpoint, position), Governor: m.Governor}
+	records, errc := reader.Start()
+	defer reader.Stop()
+
+	batchSize := m.BatchSize
+	if batchSize <= 0 || batchSize > MaxPutRecordsEntries {
+		batchSize = MaxPutRecordsEntries
+	}
+	flushInterval := m.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	// Data read from the source is already compressed if it needs to be.
+	destination := *m.Destination
+	destination.Compressor = nil
+
+	batch := []PutRecordsEntry{}
+	batchBytes := 0
+	last := ""
+	flush := func() error {
+		if len(batch) > 0 {
+			if err := destination.putInOrder(batch); err != nil {
+				return err
+			}
+			if err := checkpointer.Checkpoint(shard.ShardId, last); err != nil {
+				return err
+			}
+			batch = batch[:0]
+			batchBytes = 0
+		}
+		m.setLag(shard.ShardId, reader.MillisBehindLatest(), false)
+		return nil
+	}
+
+	for {
+		select {
+		case record, ok := <-records:
+			if !ok {
+				if err := flush(); err != nil {
+					return err
+				}
+				m.setLag(shard.ShardId, 0, true)
+				return checkpointer.Checkpoint(shard.ShardId, ShardEnd)
+			}
+
+			data, err := base64.StdEncoding.DecodeString(record.Data)
+			if err != nil {
+				return err
+			}
+			size := len(data) + len(record.PartitionKey)
+			if batchBytes+size > MaxPutRecordsBytes {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			batch = append(batch, PutRecordsEntry{PartitionKey: record.PartitionKey, Data: data})
+			batchBytes += size
+			last = record.SequenceNumber
+
+			if len(batch) >= batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		case <-ticker.C:
+			if err := flush(); err != nil {
+				return err
+			}
+		case err := <-errc:
+			if flushErr := flush(); flushErr != nil {
+				return flushErr
+			}
+			return err
+		case <-stop:
+			return flush()
+		}
+	}
+}
+
+// putInOrder puts entries with PutRecords so that they arrive in order, retrying with an exponential backoff.
+// When a record fails, it is put again together with every record after it, even those that were put.
+// It returns ErrPutRecordsFailed if records were still failing after gaws.MaxTries attempts.
+func (s *Stream) putInOrder(entries []PutRecordsEntry) error {
+	for try := 1; len(entries) > 0; try++ {
+		output, err := s.PutRecords(entries)
+		if err != nil {
+			return err
+		}
+
+		first := len(entries)
+		for i, result := range output.Records {
+			if result.ErrorCode != "" && i < len(entries) {
+				first = i
+				break
+			}
+		}
+		entries = entries[first:]
+
+		if len(entries) == 0 {
+			break
+		}
+		if try >= gaws.MaxTries {
+			return ErrPutRecordsFailed
+		}
+		time.Sleep(time.Duration(100*math.Pow(2.0, float64(try))) * time.Millisecond)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package kinesis
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/controlgroup/gaws/kinesis/kinesistest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// sliceArchiveWriter keeps archived records in a slice.
+type sliceArchiveWriter struct {
+	records []ArchivedRecord
+}
+
+func (s *sliceArchiveWriter) Write(record ArchivedRecord) error {
+	s.records = append(s.records, record)
+	return nil
+}
+
+// waitForRecords dumps stream until it holds n records or a second has passed, and returns what it holds.
+func waitForRecords(stream *Stream, n int) []ArchivedRecord {
+	deadline := time.Now().Add(time.Second)
+	for {
+		w := &sliceArchiveWriter{}
+		stream.Dump(w, DumpOptions{})
+		if len(w.records) >= n || time.Now().After(deadline) {
+			return w.records
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func partitionKeys(records []ArchivedRecord) []string {
+	keys := []string{}
+	for _, record := range records {
+		keys = append(keys, record.PartitionKey)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// runMirror runs m until the destination holds n records, then stops it and returns the records and Run's error.
+func runMirror(m *Mirror, n int) ([]ArchivedRecord, error) {
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() { done <- m.Run(stop) }()
+
+	records := waitForRecords(m.Destination, n)
+	close(stop)
+	return records, <-done
+}
+
+func TestMirror(t *testing.T) {
+	Convey("Given a source stream with two shards and a destination in another region", t, func() {
+		sourceServer := kinesistest.NewServer()
+		defer sourceServer.Close()
+		destinationServer := kinesistest.NewServer()
+		defer destinationServer.Close()
+
+		source, _ := (&KinesisService{Endpoint: sourceServer.URL}).CreateStream("source", 2)
+		destination, _ := (&KinesisService{Endpoint: destinationServer.URL}).CreateStream("destination", 1)
+		for i := 0; i < 10; i++ {
+			source.PutRecord(fmt.Sprint("key", i), []byte(fmt.Sprint("record", i)))
+		}
+
+		checkpointer := &MemoryCheckpointer{}
+		mirror := &Mirror{Source: &source, Destination: &destination, Checkpointer: checkpointer, FlushInterval: 10 * time.Millisecond}
+
+		records, err := runMirror(mirror, 10)
+		So(err, ShouldBeNil)
+
+		Convey("Every record is copied with its partition key and data", func() {
+			So(len(records), ShouldEqual, 10)
+			So(partitionKeys(records), ShouldResemble, []string{"key0", "key1", "key2", "key3", "key4", "key5", "key6", "key7", "key8", "key9"})
+			for _, record := range records {
+				So(string(record.Data), ShouldEqual, "record"+record.PartitionKey[3:])
+			}
+		})
+		Convey("Each shard is checkpointed", func() {
+			shards, _ := source.Shards()
+			for _, shard := range shards {
+				checkpoint, _ := checkpointer.LastCheckpoint(shard.ShardId)
+				So(checkpoint, ShouldNotEqual, "")
+			}
+		})
+		Convey("The mirror is caught up", func() {
+			So(mirror.Lag(), ShouldEqual, 0)
+		})
+		Convey("Running again from the checkpoints only copies new records", func() {
+			source.PutRecord("key10", []byte("record10"))
+			records, err := runMirror(&Mirror{Source: &source, Destination: &destination, Checkpointer: checkpointer, FlushInterval: 10 * time.Millisecond}, 11)
+			So(err, ShouldBeNil)
+
+			time.Sleep(50 * time.Millisecond)
+			So(len(waitForRecords(&destination, 12)), ShouldEqual, 11)
+			So(len(records), ShouldEqual, 11)
+		})
+	})
+
+	Convey("Given a source stream whose shard was split", t, func() {
+		sourceServer := kinesistest.NewServer()
+		defer sourceServer.Close()
+		destinationServer := kinesistest.NewServer()
+		defer destinationServer.Close()
+
+		source, _ := (&KinesisService{Endpoint: sourceServer.URL}).CreateStream("source", 1)
+		destination, _ := (&KinesisService{Endpoint: destinationServer.URL}).CreateStream("destination", 1)
+		for i := 0; i < 3; i++ {
+			source.PutRecord("a", []byte(fmt.Sprint(i)))
+		}
+		shards, _ := source.Shards()
+		So(shards[0].SplitEvenly(), ShouldBeNil)
+		for i := 3; i < 6; i++ {
+			source.PutRecord("a", []byte(fmt.Sprint(i)))
+		}
+
+		checkpointer := &MemoryCheckpointer{}
+		records, err := runMirror(&Mirror{Source: &source, Destination: &destination, Checkpointer: checkpointer, FlushInterval: 10 * time.Millisecond, RefreshInterval: 10 * time.Millisecond}, 6)
+		So(err, ShouldBeNil)
+
+		Convey("The parent is copied before its children, so order is kept", func() {
+			data := ""
+			for _, record := range records {
+				data += string(record.Data)
+			}
+			So(data, ShouldEqual, "012345")
+		})
+		Convey("The parent is checkpointed as finished", func() {
+			checkpoint, _ := checkpointer.LastCheckpoint(shards[0].ShardId)
+			So(checkpoint, ShouldEqual, ShardEnd)
+		})
+	})
+
+	Convey("A shard is ready once the parents still in the stream are finished", t, func() {
+		child := Shard{ShardId: "c", ParentShardId: "p", AdjacentParentShardId: "q"}
+		So(shardReady(child, map[string]bool{"p": true, "q": true}, map[string]bool{"p": true}), ShouldBeFalse)
+		So(shardReady(child, map[string]bool{"p": true, "q": true}, map[string]bool{"p": true, "q": true}), ShouldBeTrue)
+		So(shardReady(child, map[string]bool{"q": true}, map[string]bool{"q": true}), ShouldBeTrue)
+	})
+}
+
+func TestPutInOrder(t *testing.T) {
+	Convey("Given a stream that fails the first record of the first PutRecords call", t, func() {
+		server := &flakyPutRecords{}
+		ts := httptest.NewServer(server)
+		defer ts.Close()
+		ks := KinesisService{Endpoint: ts.URL}
+		testStream := Stream{Name: "foo", Service: &ks}
+
+		entries := []PutRecordsEntry{}
+		for _, data := range []string{"one", "two", "six"} {
+			entries = append(entries, PutRecordsEntry{Data: []byte(data), PartitionKey: "a"})
+		}
+		So(testStream.putInOrder(entries), ShouldBeNil)
+
+		Convey("The failed record is put again with every record after it", func() {
+			data := []string{}
+			for _, entry := range server.accepted {
+				decoded, _ := base64.StdEncoding.DecodeString(entry.Data)
+				data = append(data, string(decoded))
+			}
+			So(data, ShouldResemble, []string{"two", "six", "one", "two", "six"})
+		})
+	})
+}
@@ -0,0 +1,485 @@
+package kinesis
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Item is a value passing through a Pipeline. It starts out as a Record read from the shard, and each step replaces
+// its value. Items derived from several records, such as batches, carry all of them.
+type Item struct {
+	Value       interface{}
+	ArrivalTime time.Time // The latest arrival time of the records the item was derived from.
+
+	refs []*recordRef
+}
+
+// recordRef counts the items derived from one record that have not been handled yet.
+type recordRef struct {
+	tracker        *checkpointTracker
+	sequenceNumber string
+	pending        int
+}
+
+// checkpointTracker finds the last record that, along with every record read before it, has no items left to handle.
+type checkpointTracker struct {
+	refs []*recordRef // Every record read after the last one that is safe to checkpoint, in read order.
+	done string       // The sequence number of the last record that is safe to checkpoint.
+}
+
+// add starts tracking a record that has just been read.
+func (t *checkpointTracker) add(sequenceNumber string) *recordRef {
+	ref := &recordRef{tracker: t, sequenceNumber: sequenceNumber, pending: 1}
+	t.refs = append(t.refs, ref)
+	return ref
+}
+
+// advance moves done past the records at the front that have no items left.
+func (t *checkpointTracker) advance() {
+	for len(t.refs) > 0 && t.refs[0].pending == 0 {
+		t.done = t.refs[0].sequenceNumber
+		t.refs[0] = nil
+		t.refs = t.refs[1:]
+	}
+}
+
+// retain counts n more items derived from the item's records, for when the item is copied into n more items.
+func (item Item) retain(n int) {
+	for _, ref := range item.refs {
+		ref.pending += n
+	}
+}
+
+// release marks the item handled.
+func (item Item) release() {
+	for _, ref := range item.refs {
+		ref.pending--
+		ref.tracker.advance()
+	}
+}
+
+// mergeItems returns an item with value that is derived from every record that items were derived from.
+func mergeItems(value interface{}, items []Item) Item {
+	merged := Item{Value: value}
+	for _, item := range items {
+		merged.refs = append(merged.refs, item.refs...)
+		if item.ArrivalTime.After(merged.ArrivalTime) {
+			merged.ArrivalTime = item.ArrivalTime
+		}
+	}
+	return merged
+}
+
+// MapFunc replaces the value of an item. Returning an error ends the Pipeline.
+type MapFunc func(value interface{}) (interface{}, error)
+
+// FilterFunc reports whether an item should be kept.
+type FilterFunc func(value interface{}) bool
+
+// ItemHandler handles the items that come out of the last step of a Pipeline. Returning an error ends the Pipeline.
+type ItemHandler func(item Item) error
+
+// TimeWindow is the value of the items made by Pipeline.Window.
+type TimeWindow struct {
+	Start  time.Time
+	End    time.Time     // The window holds the items that arrived from Start up to, but not including, End.
+	Values []interface{} // In the order they were read.
+}
+
+// stage is one step of a Pipeline. It passes items on to the next step by calling emit.
+type stage interface {
+	push(item Item, emit func(Item) error) error
+	flush(emit func(Item) error) error // flush emits every item the stage is holding, once the shard has been read to its end.
+}
+
+// timedStage is a stage that also emits items as time passes.
+type timedStage interface {
+	stage
+	deadline() time.Time // When tick must next be called, or the zero time if it need not be.
+	tick(now time.Time, emit func(Item) error) error
+}
+
+type mapStage struct {
+	f MapFunc
+}
+
+func (s *mapStage) push(item Item, emit func(Item) error) error {
+	value, err := s.f(item.Value)
+	if err != nil {
+		return err
+	}
+	item.Value = value
+	return emit(item)
+}
+
+func (s *mapStage) flush(emit func(Item) error) error {
+	return nil
+}
+
+type filterStage struct {
+	f FilterFunc
+}
+
+func (s *filterStage) push(item Item, emit func(Item) error) error {
+	if !s.f(item.Value) {
+		item.release()
+		return nil
+	}
+	return emit(item)
+}
+
+func (s *filterStage) flush(emit func(Item) error) error {
+	return nil
+}
+
+type batchStage struct {
+	size     int
+	interval time.Duration
+
+	items   []Item
+	started time.Time
+}
+
+func (s *batchStage) push(item Item, emit func(Item) error) error {
+	if len(s.items) == 0 {
+		s.started = time.Now()
+	}
+	s.items = append(s.items, item)
+	if s.size > 0 && len(s.items) >= s.size {
+		return s.emitBatch(emit)
+	}
+	return nil
+}
+
+func (s *batchStage) deadline() time.Time {
+	if len(s.items) == 0 || s.interval <= 0 {
+		return time.Time{}
+	}
+	return s.started.Add(s.interval)
+}
+
+func (s *batchStage) tick(now time.Time, emit func(Item) error) error {
+	if deadline := s.deadline(); deadline.IsZero() || now.Before(deadline) {
+		return nil
+	}
+	return s.emitBatch(emit)
+}
+
+func (s *batchStage) flush(emit func(Item) error) error {
+	if len(s.items) == 0 {
+		return nil
+	}
+	return s.emitBatch(emit)
+}
+
+// emitBatch emits the items held as one item whose value is a []interface{}.
+func (s *batchStage) emitBatch(emit func(Item) error) error {
+	values := make([]interface{}, len(s.items))
+	for i, item := range s.items {
+		values[i] = item.Value
+	}
+	batch := mergeItems(values, s.items)
+	s.items = nil
+	return emit(batch)
+}
+
+// openWindow is a window that has not been emitted yet.
+type openWindow struct {
+	start time.Time
+	items []Item
+}
+
+type windowStage struct {
+	size  time.Duration
+	slide time.Duration
+
+	windows   []*openWindow // Sorted by start.
+	watermark time.Time     // The latest arrival time pushed. Every window that ends by then has been emitted.
+}
+
+// window returns the open window that starts at start, opening it if needed.
+func (s *windowStage) window(start time.Time) *openWindow {
+	i := 0
+	for i < len(s.windows) && s.windows[i].start.Before(start) {
+		i++
+	}
+	if i < len(s.windows) && s.windows[i].start.Equal(start) {
+		return s.windows[i]
+	}
+
+	w := &openWindow{start: start}
+	s.windows = append(s.windows, nil)
+	copy(s.windows[i+1:], s.windows[i:])
+	s.windows[i] = w
+	return w
+}
+
+func (s *windowStage) push(item Item, emit func(Item) error) error {
+	arrival := item.ArrivalTime
+
+	// The windows that hold arrival start at multiples of slide after arrival-size, up to arrival.
+	windows := 0
+	for start := arrival.Truncate(s.slide); start.After(arrival.Add(-s.size)); start = start.Add(-s.slide) {
+		if !start.Add(s.size).After(s.watermark) {
+			// The window has already been emitted, so the item is too late for it.
+			continue
+		}
+		w := s.window(start)
+		w.items = append(w.items, item)
+		windows++
+	}
+	if windows == 0 {
+		item.release()
+	} else {
+		item.retain(windows - 1)
+	}
+
+	if arrival.After(s.watermark) {
+		s.watermark = arrival
+	}
+	for len(s.windows) > 0 && !s.windows[0].start.Add(s.size).After(s.watermark) {
+		if err := s.emitWindow(emit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *windowStage) flush(emit func(Item) error) error {
+	for len(s.windows) > 0 {
+		if err := s.emitWindow(emit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emitWindow emits the earliest open window.
+func (s *windowStage) emitWindow(emit func(Item) error) error {
+	w := s.windows[0]
+	s.windows = s.windows[1:]
+
+	window := TimeWindow{Start: w.start, End: w.start.Add(s.size), Values: make([]interface{}, len(w.items))}
+	for i, item := range w.items {
+		window.Values[i] = item.Value
+	}
+	return emit(mergeItems(window, w.items))
+}
+
+// Pipeline reads a shard with Reader and passes each record through a chain of steps to a handler.
+// Steps are added with Map, Filter, Batch, Window and Decode, in the order they run, and Run starts reading.
+//
+// With a Checkpointer, a record is only checkpointed once every item derived from it, and from every record read
+// before it, has been handled or filtered out. A consumer that restarts therefore never skips a record whose items
+// were still waiting in a batch or window. A Pipeline can be run once.
+type Pipeline struct {
+	Reader             *ShardReader
+	Checkpointer       Checkpointer  // Optional. Run resumes the shard from its checkpoint and saves progress to it.
+	CheckpointInterval time.Duration // The least time between checkpoints while running. 0 checkpoints whenever there is progress.
+
+	stages         []stage
+	err            error // An invalid step, which Run returns before reading anything.
+	tracker        checkpointTracker
+	checkpointed   string
+	checkpointedAt time.Time
+}
+
+// Map adds a step that replaces the value of each item with what f returns.
+func (p *Pipeline) Map(f MapFunc) *Pipeline {
+	p.stages = append(p.stages, &mapStage{f: f})
+	return p
+}
+
+// Filter adds a step that drops the items that f returns false for. Dropped items count as handled.
+func (p *Pipeline) Filter(f FilterFunc) *Pipeline {
+	p.stages = append(p.stages, &filterStage{f: f})
+	return p
+}
+
+// Batch adds a step that collects items into batches, whose values are []interface{}. A batch is passed on once it
+// holds size items or interval after its first item was added, whichever comes first. 0 disables either limit.
+func (p *Pipeline) Batch(size int, interval time.Duration) *Pipeline {
+	p.stages = append(p.stages, &batchStage{size: size, interval: interval})
+	return p
+}
+
+// ErrInvalidWindow is returned by Run for a pipeline with a Window whose size is not positive.
+var ErrInvalidWindow = errors.New("kinesis: window size must be positive")
+
+// Window adds a step that groups items into windows of size by their arrival time, and passes on a TimeWindow for each.
+// A new window starts every slide. If slide is 0 or less, it defaults to size and the windows are tumbling, so every
+// item is in exactly one; if slide is less than size, the windows are sliding and overlap. If slide is more than size,
+// there are gaps between the windows, and items that arrive in a gap are in no window: they are dropped and count as
+// handled. A window is passed on once an item arrives after it ends, or when the shard has been read to its end.
+// Items that arrive after their windows were passed on are dropped. If size is not positive, Run returns ErrInvalidWindow.
+func (p *Pipeline) Window(size time.Duration, slide time.Duration) *Pipeline {
+	if size <= 0 {
+		if p.err == nil {
+			p.err = ErrInvalidWindow
+		}
+		return p
+	}
+	if slide <= 0 {
+		slide = size
+	}
+	p.stages = append(p.stages, &windowStage{size: size, slide: slide})
+	return p
+}
+
+// Decode adds a step that decodes each Record with the stream's Codec into the value newValue returns, which must be a pointer.
+func (p *Pipeline) Decode(newValue func() interface{}) *Pipeline {
+	codec := DefaultCodec
+	if p.Reader.Shard.stream != nil {
+		codec = p.Reader.Shard.stream.codec()
+	}
+	return p.Map(func(value interface{}) (interface{}, error) {
+		record, ok := value.(Record)
+		if !ok {
+			return nil, fmt.Errorf("kinesis: Decode needs a Record, not a %T", value)
+		}
+		v := newValue()
+		return v, record.DecodeWith(codec, v)
+	})
+}
+
+// Run starts the reader and passes every record through the pipeline to handler, checkpointing as items are handled.
+// It returns nil when the record channel is closed or the reader is stopped, and otherwise the error that ended it.
+// When the channel is closed, the items held in batches and windows are passed on first, and if the shard was read
+// to its end without StopAtLatest, it is checkpointed as ShardEnd.
+func (p *Pipeline) Run(handler ItemHandler) error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.Checkpointer != nil {
+		checkpoint, err := p.Checkpointer.LastCheckpoint(p.Reader.Shard.ShardId)
+		if err != nil {
+			return err
+		}
+		if checkpoint == ShardEnd {
+			return nil
+		}
+		p.checkpointed = checkpoint
+		p.Reader.Position = checkpointPosition(checkpoint, p.Reader.Position)
+	}
+	p.checkpointedAt = time.Now()
+
+	// emits[i] passes an item to stage i, and the last one to handler.
+	emits := make([]func(Item) error, len(p.stages)+1)
+	emits[len(p.stages)] = func(item Item) error {
+		if err := handler(item); err != nil {
+			return err
+		}
+		item.release()
+		return nil
+	}
+	for i := len(p.stages) - 1; i >= 0; i-- {
+		stage, next := p.stages[i], emits[i+1]
+		emits[i] = func(item Item) error {
+			return stage.push(item, next)
+		}
+	}
+
+	records, errc := p.Reader.Start()
+	defer p.Reader.Stop()
+
+	p.Reader.mu.Lock()
+	stop := p.Reader.stop
+	p.Reader.mu.Unlock()
+
+	for {
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if deadline := p.deadline(); !deadline.IsZero() {
+			timer = time.NewTimer(deadline.Sub(time.Now()))
+			timeout = timer.C
+		}
+
+		var err error
+		select {
+		case record, ok := <-records:
+			if !ok && !p.Reader.finished() {
+				return p.checkpoint(true)
+			}
+			if !ok {
+				return p.finish(emits)
+			}
+			ref := p.tracker.add(record.SequenceNumber)
+			err = emits[0](Item{Value: record, ArrivalTime: record.ArrivalTime(), refs: []*recordRef{ref}})
+		case now := <-timeout:
+			err = p.tick(now, emits)
+		case err = <-errc:
+		case <-stop:
+			return p.checkpoint(true)
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		if err != nil {
+			// Save what was handled before the failure. The failure is the error to report.
+			p.checkpoint(true)
+			return err
+		}
+		if err := p.checkpoint(false); err != nil {
+			return err
+		}
+	}
+}
+
+// deadline is the earliest time any stage must be ticked, or the zero time if none must be.
+func (p *Pipeline) deadline() time.Time {
+	earliest := time.Time{}
+	for _, s := range p.stages {
+		timed, ok := s.(timedStage)
+		if !ok {
+			continue
+		}
+		if deadline := timed.deadline(); !deadline.IsZero() && (earliest.IsZero() || deadline.Before(earliest)) {
+			earliest = deadline
+		}
+	}
+	return earliest
+}
+
+// tick lets every timed stage emit what is due at now.
+func (p *Pipeline) tick(now time.Time, emits []func(Item) error) error {
+	for i, s := range p.stages {
+		if timed, ok := s.(timedStage); ok {
+			if err := timed.tick(now, emits[i+1]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// finish flushes the stages in order, so each one's items reach the next before it is flushed, then checkpoints.
+func (p *Pipeline) finish(emits []func(Item) error) error {
+	for i, s := range p.stages {
+		if err := s.flush(emits[i+1]); err != nil {
+			p.checkpoint(true)
+			return err
+		}
+	}
+	if !p.Reader.StopAtLatest && len(p.tracker.refs) == 0 {
+		p.tracker.done = ShardEnd
+	}
+	return p.checkpoint(true)
+}
+
+// checkpoint saves the tracker's progress if there is any, and unless force is set, if CheckpointInterval has passed.
+func (p *Pipeline) checkpoint(force bool) error {
+	if p.Checkpointer == nil || p.tracker.done == "" || p.tracker.done == p.checkpointed {
+		return nil
+	}
+	if !force && time.Since(p.checkpointedAt) < p.CheckpointInterval {
+		return nil
+	}
+	if err := p.Checkpointer.Checkpoint(p.Reader.Shard.ShardId, p.tracker.done); err != nil {
+		return err
+	}
+	p.checkpointed = p.tracker.done
+	p.checkpointedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,242 @@
+package kinesis
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/controlgroup/gaws/kinesis/kinesistest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// ints returns the decoded values of a batch or window of *int.
+func ints(values []interface{}) []int {
+	result := []int{}
+	for _, v := range values {
+		result = append(result, *v.(*int))
+	}
+	return result
+}
+
+func decodeInt() interface{} {
+	return new(int)
+}
+
+func TestPipeline(t *testing.T) {
+	Convey("Given a shard holding the numbers 1 to 5", t, func() {
+		server := kinesistest.NewServer()
+		defer server.Close()
+		ks := KinesisService{Endpoint: server.URL}
+		stream, _ := ks.CreateStream("foo", 1)
+		for i := 1; i <= 5; i++ {
+			stream.PutValue("a", i)
+		}
+		shards, _ := stream.Shards()
+		checkpointer := &MemoryCheckpointer{}
+
+		sequenceNumbers := []string{}
+		reader := &ShardReader{Shard: &shards[0], StopAtLatest: true}
+		records, _ := reader.Start()
+		for record := range records {
+			sequenceNumbers = append(sequenceNumbers, record.SequenceNumber)
+		}
+
+		Convey("Records are decoded, filtered, mapped and batched", func() {
+			p := &Pipeline{Reader: &ShardReader{Shard: &shards[0], StopAtLatest: true}, Checkpointer: checkpointer}
+			p.Decode(decodeInt).Filter(func(v interface{}) bool {
+				return *v.(*int)%2 == 1
+			}).Map(func(v interface{}) (interface{}, error) {
+				n := *v.(*int) * 10
+				return &n, nil
+			}).Batch(2, 0)
+
+			batches := [][]int{}
+			err := p.Run(func(item Item) error {
+				batches = append(batches, ints(item.Value.([]interface{})))
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(batches, ShouldResemble, [][]int{{10, 30}, {50}})
+
+			checkpoint, _ := checkpointer.LastCheckpoint(shards[0].ShardId)
+			So(checkpoint, ShouldEqual, sequenceNumbers[4])
+		})
+		Convey("Filtered out records are checkpointed", func() {
+			p := &Pipeline{Reader: &ShardReader{Shard: &shards[0], StopAtLatest: true}, Checkpointer: checkpointer}
+			p.Decode(decodeInt).Filter(func(v interface{}) bool {
+				return *v.(*int) < 3
+			})
+
+			So(p.Run(func(item Item) error { return nil }), ShouldBeNil)
+			checkpoint, _ := checkpointer.LastCheckpoint(shards[0].ShardId)
+			So(checkpoint, ShouldEqual, sequenceNumbers[4])
+		})
+		Convey("When the handler fails, only the records of handled batches are checkpointed", func() {
+			p := &Pipeline{Reader: &ShardReader{Shard: &shards[0], StopAtLatest: true}, Checkpointer: checkpointer}
+			p.Decode(decodeInt).Batch(2, 0)
+
+			failure := errors.New("failed")
+			err := p.Run(func(item Item) error {
+				if ints(item.Value.([]interface{}))[0] == 3 {
+					return failure
+				}
+				return nil
+			})
+			So(err, ShouldEqual, failure)
+			checkpoint, _ := checkpointer.LastCheckpoint(shards[0].ShardId)
+			So(checkpoint, ShouldEqual, sequenceNumbers[1])
+
+			Convey("Running again resumes after the checkpoint", func() {
+				p := &Pipeline{Reader: &ShardReader{Shard: &shards[0], StopAtLatest: true}, Checkpointer: checkpointer}
+				p.Decode(decodeInt).Batch(2, 0)
+
+				batches := [][]int{}
+				p.Run(func(item Item) error {
+					batches = append(batches, ints(item.Value.([]interface{})))
+					return nil
+				})
+				So(batches, ShouldResemble, [][]int{{3, 4}, {5}})
+			})
+		})
+		Convey("A Map error ends the pipeline", func() {
+			p := &Pipeline{Reader: &ShardReader{Shard: &shards[0], StopAtLatest: true}, Checkpointer: checkpointer}
+			p.Map(func(v interface{}) (interface{}, error) {
+				return nil, errors.New("cannot map")
+			})
+
+			So(p.Run(func(item Item) error { return nil }), ShouldNotBeNil)
+			checkpoint, _ := checkpointer.LastCheckpoint(shards[0].ShardId)
+			So(checkpoint, ShouldEqual, "")
+		})
+		Convey("A batch is passed on once its interval has passed", func() {
+			p := &Pipeline{Reader: &ShardReader{Shard: &shards[0]}, Checkpointer: checkpointer}
+			p.Decode(decodeInt).Batch(100, 20*time.Millisecond)
+
+			batches := [][]int{}
+			err := p.Run(func(item Item) error {
+				batches = append(batches, ints(item.Value.([]interface{})))
+				p.Reader.Stop()
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(batches, ShouldResemble, [][]int{{1, 2, 3, 4, 5}})
+
+			checkpoint, _ := checkpointer.LastCheckpoint(shards[0].ShardId)
+			So(checkpoint, ShouldEqual, sequenceNumbers[4])
+		})
+		Convey("A window without a size is an error before anything is read", func() {
+			p := &Pipeline{Reader: &ShardReader{Shard: &shards[0], StopAtLatest: true}, Checkpointer: checkpointer}
+			p.Decode(decodeInt).Window(0, 0)
+
+			So(p.Run(func(item Item) error { return nil }), ShouldEqual, ErrInvalidWindow)
+			checkpoint, _ := checkpointer.LastCheckpoint(shards[0].ShardId)
+			So(checkpoint, ShouldEqual, "")
+		})
+		Convey("A closed shard read to its end is checkpointed as ShardEnd", func() {
+			So(shards[0].SplitEvenly(), ShouldBeNil)
+
+			p := &Pipeline{Reader: &ShardReader{Shard: &shards[0]}, Checkpointer: checkpointer}
+			p.Decode(decodeInt).Batch(2, 0)
+
+			count := 0
+			So(p.Run(func(item Item) error {
+				count++
+				return nil
+			}), ShouldBeNil)
+			So(count, ShouldEqual, 3)
+
+			checkpoint, _ := checkpointer.LastCheckpoint(shards[0].ShardId)
+			So(checkpoint, ShouldEqual, ShardEnd)
+
+			Convey("And is not read again", func() {
+				p := &Pipeline{Reader: &ShardReader{Shard: &shards[0]}, Checkpointer: checkpointer}
+				So(p.Run(func(item Item) error {
+					count++
+					return nil
+				}), ShouldBeNil)
+				So(count, ShouldEqual, 3)
+			})
+		})
+	})
+}
+
+// windowItems returns an item for each arrival time, in seconds after base, tracked by tracker.
+// Each item's value is its arrival time in seconds.
+func windowItems(tracker *checkpointTracker, base time.Time, seconds ...int) []Item {
+	items := []Item{}
+	for i, s := range seconds {
+		ref := tracker.add(string(rune('a' + i)))
+		item := Item{Value: s, ArrivalTime: base.Add(time.Duration(s) * time.Second), refs: []*recordRef{ref}}
+		items = append(items, item)
+	}
+	return items
+}
+
+func TestWindows(t *testing.T) {
+	Convey("Given items that arrived at different times", t, func() {
+		base := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+		tracker := &checkpointTracker{}
+
+		windows := []TimeWindow{}
+		emitted := []Item{}
+		emit := func(item Item) error {
+			windows = append(windows, item.Value.(TimeWindow))
+			emitted = append(emitted, item)
+			return nil
+		}
+
+		Convey("Tumbling windows hold each item once", func() {
+			s := &windowStage{size: 10 * time.Second, slide: 10 * time.Second}
+			for _, item := range windowItems(tracker, base, 1, 5, 12, 25) {
+				So(s.push(item, emit), ShouldBeNil)
+			}
+			So(len(windows), ShouldEqual, 2)
+			So(windows[0].Start, ShouldResemble, base)
+			So(windows[0].End, ShouldResemble, base.Add(10*time.Second))
+			So(windows[0].Values, ShouldResemble, []interface{}{1, 5})
+			So(windows[1].Values, ShouldResemble, []interface{}{12})
+
+			So(s.flush(emit), ShouldBeNil)
+			So(len(windows), ShouldEqual, 3)
+			So(windows[2].Values, ShouldResemble, []interface{}{25})
+
+			Convey("Late items are dropped and count as handled", func() {
+				late := Item{Value: 3, ArrivalTime: base.Add(3 * time.Second), refs: []*recordRef{tracker.add("e")}}
+				So(s.push(late, emit), ShouldBeNil)
+				for _, item := range emitted {
+					item.release()
+				}
+				So(tracker.done, ShouldEqual, "e")
+			})
+		})
+		Convey("Items in the gaps between windows that slide further than their size are dropped", func() {
+			s := &windowStage{size: 5 * time.Second, slide: 10 * time.Second}
+			for _, item := range windowItems(tracker, base, 2, 7, 12) {
+				So(s.push(item, emit), ShouldBeNil)
+			}
+			So(s.flush(emit), ShouldBeNil)
+			So(len(windows), ShouldEqual, 2)
+			So(windows[0].Values, ShouldResemble, []interface{}{2})
+			So(windows[1].Values, ShouldResemble, []interface{}{12})
+		})
+		Convey("Sliding windows overlap", func() {
+			s := &windowStage{size: 10 * time.Second, slide: 5 * time.Second}
+			for _, item := range windowItems(tracker, base, 7, 12, 21) {
+				So(s.push(item, emit), ShouldBeNil)
+			}
+			So(len(windows), ShouldEqual, 3)
+			So(windows[0].Values, ShouldResemble, []interface{}{7})
+			So(windows[1].Values, ShouldResemble, []interface{}{7, 12})
+			So(windows[2].Values, ShouldResemble, []interface{}{12})
+
+			Convey("A record is only done once every window holding it is", func() {
+				emitted[0].release()
+				So(tracker.done, ShouldEqual, "")
+				emitted[1].release()
+				So(tracker.done, ShouldEqual, "a")
+				emitted[2].release()
+				So(tracker.done, ShouldEqual, "b")
+			})
+		})
+	})
+}
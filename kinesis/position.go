@@ -0,0 +1,78 @@
+package kinesis
+
+import (
+	"errors"
+	"time"
+)
+
+// shardIteratorType is a ShardIteratorType accepted by GetShardIterator.
+type shardIteratorType string
+
+const (
+	atSequenceNumber    shardIteratorType = "AT_SEQUENCE_NUMBER"
+	afterSequenceNumber shardIteratorType = "AFTER_SEQUENCE_NUMBER"
+	trimHorizon         shardIteratorType = "TRIM_HORIZON"
+	latest              shardIteratorType = "LATEST"
+	atTimestamp         shardIteratorType = "AT_TIMESTAMP"
+)
+
+// StartPosition is where a shard iterator starts reading. Use TrimHorizon, Latest, AtSequence, AfterSequence or AtTimestamp to make one.
+type StartPosition struct {
+	iteratorType   shardIteratorType
+	sequenceNumber string
+	timestamp      time.Time
+}
+
+// TrimHorizon starts at the oldest record in the shard.
+func TrimHorizon() StartPosition {
+	return StartPosition{iteratorType: trimHorizon}
+}
+
+// Latest starts just after the most recent record in the shard, so only new records are read.
+func Latest() StartPosition {
+	return StartPosition{iteratorType: latest}
+}
+
+// AtSequence starts at the record with sequenceNumber.
+func AtSequence(sequenceNumber string) StartPosition {
+	return StartPosition{iteratorType: atSequenceNumber, sequenceNumber: sequenceNumber}
+}
+
+// AfterSequence starts at the record right after the one with sequenceNumber.
+func AfterSequence(sequenceNumber string) StartPosition {
+	return StartPosition{iteratorType: afterSequenceNumber, sequenceNumber: sequenceNumber}
+}
+
+// AtTimestamp starts at the first record that arrived at or after t.
+func AtTimestamp(t time.Time) StartPosition {
+	return StartPosition{iteratorType: atTimestamp, timestamp: t}
+}
+
+// String returns the ShardIteratorType of the position.
+func (p StartPosition) String() string {
+	return string(p.iteratorType)
+}
+
+// Validate returns an error if the position cannot be sent to GetShardIterator.
+func (p StartPosition) Validate() error {
+	switch p.iteratorType {
+	case trimHorizon, latest:
+		return nil
+	case atSequenceNumber, afterSequenceNumber:
+		if p.sequenceNumber == "" {
+			return errors.New("kinesis: " + string(p.iteratorType) + " needs a sequence number")
+		}
+		for _, c := range p.sequenceNumber {
+			if c < '0' || c > '9' {
+				return errors.New("kinesis: sequence number " + p.sequenceNumber + " is not a number")
+			}
+		}
+		return nil
+	case atTimestamp:
+		if p.timestamp.IsZero() {
+			return errors.New("kinesis: AT_TIMESTAMP needs a timestamp")
+		}
+		return nil
+	}
+	return errors.New("kinesis: start position has no iterator type")
+}
@@ -0,0 +1,59 @@
+package kinesis
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStartPositionValidate(t *testing.T) {
+	Convey("Positions that need nothing else are valid", t, func() {
+		So(TrimHorizon().Validate(), ShouldBeNil)
+		So(Latest().Validate(), ShouldBeNil)
+	})
+	Convey("Sequence number positions need a numeric sequence number", t, func() {
+		So(AtSequence("12345").Validate(), ShouldBeNil)
+		So(AfterSequence("12345").Validate(), ShouldBeNil)
+		So(AtSequence("").Validate(), ShouldNotBeNil)
+		So(AfterSequence("12a45").Validate(), ShouldNotBeNil)
+	})
+	Convey("AT_TIMESTAMP needs a timestamp", t, func() {
+		So(AtTimestamp(time.Now()).Validate(), ShouldBeNil)
+		So(AtTimestamp(time.Time{}).Validate(), ShouldNotBeNil)
+	})
+	Convey("The zero StartPosition is not valid", t, func() {
+		So(StartPosition{}.Validate(), ShouldNotBeNil)
+	})
+}
+
+func TestGetShardIteratorAtTimestamp(t *testing.T) {
+	Convey("Given a Shard and a server that records GetShardIterator requests", t, func() {
+		var sent map[string]interface{}
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &sent)
+			testGetShardIteratorSuccess(w, r)
+		}))
+		ks := KinesisService{Endpoint: ts.URL}
+		testStream := Stream{Name: "foo", Service: &ks}
+		testShard := Shard{ShardId: "TestShard", stream: &testStream}
+
+		Convey("Using AtTimestamp sends the time in epoch seconds", func() {
+			_, err := testShard.GetShardIterator(AtTimestamp(time.Unix(1500000000, 500000000)))
+			So(err, ShouldBeNil)
+			So(sent["ShardIteratorType"], ShouldEqual, "AT_TIMESTAMP")
+			So(sent["Timestamp"], ShouldEqual, 1500000000.5)
+			So(sent["StartingSequenceNumber"], ShouldBeNil)
+		})
+		Convey("Using an invalid position returns an error without making a request", func() {
+			_, err := testShard.GetShardIterator(AtSequence(""))
+			So(err, ShouldNotBeNil)
+			So(sent, ShouldBeNil)
+		})
+	})
+}
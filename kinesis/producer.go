@@ -0,0 +1,76 @@
+package kinesis
+
+// Producer puts records on a stream. It shapes its traffic with Limiter, if it has one, so shards are not sent more
+// than Kinesis allows, and retries the records in a PutRecords call that fail.
+type Producer struct {
+	Stream  *Stream
+	Limiter *ShardLimiter // Optional. Records are counted at their size after compression, as Kinesis counts them.
+}
+
+// Put waits for the record's shard to have capacity and puts the record with PutRecord.
+// If partitionKey is empty, the stream's Keyer chooses one.
+func (p *Producer) Put(partitionKey string, data []byte) error {
+	partitionKey, explicitHashKey, err := p.Stream.assignKey(partitionKey, "", data)
+	if err != nil {
+		return err
+	}
+	data, err = p.Stream.compress(data)
+	if err != nil {
+		return err
+	}
+	if p.Limiter != nil {
+		if err := p.Limiter.Wait(partitionKey, explicitHashKey, len(data)); err != nil {
+			return err
+		}
+	}
+	return p.uncompressed().putRecord(partitionKey, explicitHashKey, data)
+}
+
+// uncompressed returns a copy of the stream without a Compressor, for putting data that has been compressed already.
+func (p *Producer) uncompressed() *Stream {
+	stream := *p.Stream
+	stream.Compressor = nil
+	return &stream
+}
+
+// PutRecords waits for each record's shard to have capacity and puts the records in batches that PutRecords accepts,
+// of up to MaxPutRecordsEntries records and MaxPutRecordsBytes. Records that fail are retried with an exponential backoff.
+// It returns the number of records put. Entries without a partition key are given one by the stream's Keyer before
+// they are first sent, and keep it when retried. If any record is larger than MaxRecordBytes after compression,
+// nothing is put and ErrRecordTooLarge is returned.
+func (p *Producer) PutRecords(entries []PutRecordsEntry) (int, error) {
+	prepared := make([]PutRecordsEntry, len(entries))
+	for i, entry := range entries {
+		partitionKey, explicitHashKey, err := p.Stream.assignKey(entry.PartitionKey, entry.ExplicitHashKey, entry.Data)
+		if err != nil {
+			return 0, err
+		}
+		data, err := p.Stream.compress(entry.Data)
+		if err != nil {
+			return 0, err
+		}
+		prepared[i] = PutRecordsEntry{PartitionKey: partitionKey, ExplicitHashKey: explicitHashKey, Data: data}
+	}
+	batches, err := batchEntries(prepared)
+	if err != nil {
+		return 0, err
+	}
+
+	put := 0
+	for _, batch := range batches {
+		if p.Limiter != nil {
+			for _, entry := range batch {
+				if err := p.Limiter.Wait(entry.PartitionKey, entry.ExplicitHashKey, len(entry.Data)); err != nil {
+					return put, err
+				}
+			}
+		}
+
+		n, err := p.uncompressed().putAll(batch)
+		put += n
+		if err != nil {
+			return put, err
+		}
+	}
+	return put, nil
+}
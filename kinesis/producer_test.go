@@ -0,0 +1,86 @@
+package kinesis
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/controlgroup/gaws/kinesis/kinesistest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestProducer(t *testing.T) {
+	Convey("Given a producer with a limiter on a stream with one shard", t, func() {
+		server := kinesistest.NewServer()
+		defer server.Close()
+		ks := KinesisService{Endpoint: server.URL}
+		stream, _ := ks.CreateStream("foo", 1)
+
+		producer := Producer{Stream: &stream, Limiter: &ShardLimiter{Stream: &stream}}
+
+		Convey("Put puts a record", func() {
+			So(producer.Put("a", []byte("one")), ShouldBeNil)
+			So(server.Requests("PutRecord"), ShouldEqual, 1)
+		})
+		Convey("PutRecords splits more than 500 records into batches", func() {
+			entries := []PutRecordsEntry{}
+			for i := 0; i < 600; i++ {
+				entries = append(entries, PutRecordsEntry{PartitionKey: fmt.Sprint(i), Data: []byte("x")})
+			}
+			n, err := producer.PutRecords(entries)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 600)
+			So(server.Requests("PutRecords"), ShouldEqual, 2)
+		})
+		Convey("PutRecords splits batches larger than MaxPutRecordsBytes", func() {
+			producer.Limiter = nil
+			entries := []PutRecordsEntry{}
+			for i := 0; i < 60; i++ {
+				entries = append(entries, PutRecordsEntry{PartitionKey: fmt.Sprint(i), Data: make([]byte, 100<<10)})
+			}
+			n, err := producer.PutRecords(entries)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 60)
+			So(server.Requests("PutRecords"), ShouldEqual, 2)
+		})
+		Convey("PutRecords puts nothing if a record is larger than MaxRecordBytes", func() {
+			entries := []PutRecordsEntry{
+				{PartitionKey: "a", Data: []byte("x")},
+				{PartitionKey: "b", Data: make([]byte, MaxRecordBytes)},
+			}
+			n, err := producer.PutRecords(entries)
+			So(err, ShouldEqual, ErrRecordTooLarge)
+			So(n, ShouldEqual, 0)
+			So(server.Requests("PutRecords"), ShouldEqual, 0)
+		})
+	})
+	Convey("Given a producer with a compressor and a limiter allowing 200 bytes a second", t, func() {
+		server := kinesistest.NewServer()
+		defer server.Close()
+		ks := KinesisService{Endpoint: server.URL}
+		stream, _ := ks.CreateStream("foo", 1)
+		stream.Compressor = GzipCompressor{}
+
+		producer := Producer{Stream: &stream, Limiter: &ShardLimiter{Stream: &stream, BytesPerSecond: 200}}
+		data := make([]byte, 1000)
+
+		Convey("Records are limited at their compressed size", func() {
+			start := time.Now()
+			So(producer.Put("a", data), ShouldBeNil)
+			n, err := producer.PutRecords([]PutRecordsEntry{{PartitionKey: "a", Data: data}})
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			So(time.Since(start), ShouldBeLessThan, time.Second)
+
+			Convey("And are compressed once", func() {
+				shards, _ := stream.Shards()
+				reader := &ShardReader{Shard: &shards[0], StopAtLatest: true}
+				records, _ := reader.Start()
+				for record := range records {
+					b, _ := record.Bytes()
+					So(b, ShouldResemble, data)
+				}
+			})
+		})
+	})
+}
@@ -0,0 +1,189 @@
+package kinesis
+
+import (
+	"math/big"
+	"sync"
+	"time"
+)
+
+// TokenBucket is a rate limiter that refills at Rate tokens per second up to Burst tokens. It is safe for concurrent use.
+// Taking more tokens than are available puts the bucket into debt, and the taker waits until the debt is repaid,
+// so a single request larger than Burst is slowed down rather than refused.
+type TokenBucket struct {
+	Rate  float64 // Tokens added per second.
+	Burst float64 // The most tokens the bucket holds.
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	sleep  func(time.Duration)
+}
+
+// NewTokenBucket returns a full bucket.
+func NewTokenBucket(rate float64, burst float64) *TokenBucket {
+	return &TokenBucket{Rate: rate, Burst: burst, tokens: burst}
+}
+
+func (b *TokenBucket) clock() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+	return time.Now()
+}
+
+// reserve takes n tokens and returns how long the caller must wait before using them. Callers hold b.mu.
+func (b *TokenBucket) reserve(n float64) time.Duration {
+	now := b.clock()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.Rate
+		if b.tokens > b.Burst {
+			b.tokens = b.Burst
+		}
+	}
+	b.last = now
+
+	b.tokens -= n
+	if b.tokens >= 0 || b.Rate <= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.Rate * float64(time.Second))
+}
+
+// Wait takes n tokens, sleeping until they are available.
+func (b *TokenBucket) Wait(n float64) {
+	b.mu.Lock()
+	wait := b.reserve(n)
+	sleep := b.sleep
+	b.mu.Unlock()
+
+	if wait <= 0 {
+		return
+	}
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+	sleep(wait)
+}
+
+// delay takes n tokens and returns how long the caller must wait before using them.
+func (b *TokenBucket) delay(n float64) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.reserve(n)
+}
+
+// Take takes n tokens without waiting, putting the bucket into debt if there are not enough. Later calls to Wait repay the debt.
+func (b *TokenBucket) Take(n float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reserve(n)
+}
+
+// TryTake takes n tokens if they are available now and reports whether it did.
+func (b *TokenBucket) TryTake(n float64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.reserve(n) > 0 {
+		b.tokens += n
+		return false
+	}
+	return true
+}
+
+// shardBuckets limits the records and bytes written to one shard.
+type shardBuckets struct {
+	records *TokenBucket
+	bytes   *TokenBucket
+}
+
+// ShardLimiter shapes writes to a stream so that no shard is sent more than Kinesis allows. It predicts each record's
+// shard from its hash key and waits on that shard's token buckets. It is safe for concurrent use.
+type ShardLimiter struct {
+	Stream           *Stream
+	RecordsPerSecond float64       // Records each shard may take per second. Defaults to ShardWriteRecordsPerSecond.
+	BytesPerSecond   float64       // Bytes each shard may take per second. Defaults to ShardWriteBytesPerSecond.
+	RefreshInterval  time.Duration // How often to describe the stream again to pick up resharding. Defaults to one minute.
+
+	mu        sync.Mutex
+	shards    []Shard
+	refreshed time.Time
+	buckets   map[string]*shardBuckets
+}
+
+// shardFor returns the ID of the open shard for hashKey, describing the stream if the shard list is stale or does not cover the key.
+func (l *ShardLimiter) shardFor(hashKey *big.Int) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	interval := l.RefreshInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	if time.Since(l.refreshed) < interval {
+		if shard, err := ShardForHashKey(l.shards, hashKey); err == nil {
+			return shard.ShardId, nil
+		}
+	}
+
+	shards, err := l.Stream.OpenShards()
+	if err != nil {
+		return "", err
+	}
+	l.shards = shards
+	l.refreshed = time.Now()
+
+	shard, err := ShardForHashKey(l.shards, hashKey)
+	return shard.ShardId, err
+}
+
+// bucketsFor returns the token buckets for a shard, creating full ones the first time the shard is seen.
+func (l *ShardLimiter) bucketsFor(shardId string) *shardBuckets {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.buckets == nil {
+		l.buckets = map[string]*shardBuckets{}
+	}
+	buckets, ok := l.buckets[shardId]
+	if !ok {
+		records, bytes := l.RecordsPerSecond, l.BytesPerSecond
+		if records <= 0 {
+			records = ShardWriteRecordsPerSecond
+		}
+		if bytes <= 0 {
+			bytes = ShardWriteBytesPerSecond
+		}
+		buckets = &shardBuckets{records: NewTokenBucket(records, records), bytes: NewTokenBucket(bytes, bytes)}
+		l.buckets[shardId] = buckets
+	}
+	return buckets
+}
+
+// recordHashKey is the hash key Kinesis uses to place a record.
+func recordHashKey(partitionKey string, explicitHashKey string) (*big.Int, error) {
+	if explicitHashKey == "" {
+		return HashKey(partitionKey), nil
+	}
+	return parseHashKey(explicitHashKey)
+}
+
+// Wait blocks until the shard that partitionKey, or explicitHashKey if it is set, maps to can take a record of size bytes.
+// Kinesis counts the partition key against the shard's byte limit, so it is added to size.
+func (l *ShardLimiter) Wait(partitionKey string, explicitHashKey string, size int) error {
+	hashKey, err := recordHashKey(partitionKey, explicitHashKey)
+	if err != nil {
+		return err
+	}
+	shardId, err := l.shardFor(hashKey)
+	if err != nil {
+		return err
+	}
+
+	buckets := l.bucketsFor(shardId)
+	buckets.records.Wait(1)
+	buckets.bytes.Wait(float64(size + len(partitionKey)))
+	return nil
+}
@@ -0,0 +1,90 @@
+package kinesis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/controlgroup/gaws/kinesis/kinesistest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeClock is a clock for tests that only moves when something sleeps.
+type fakeClock struct {
+	now   time.Time
+	slept time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.slept += d
+	c.now = c.now.Add(d)
+}
+
+func testBucket(rate float64, burst float64) (*TokenBucket, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1500000000, 0)}
+	b := NewTokenBucket(rate, burst)
+	b.now = clock.Now
+	b.sleep = clock.Sleep
+	return b, clock
+}
+
+func TestTokenBucket(t *testing.T) {
+	Convey("Given a full bucket of 10 tokens refilling at 10 a second", t, func() {
+		b, clock := testBucket(10, 10)
+
+		Convey("Taking the whole burst does not wait", func() {
+			b.Wait(10)
+			So(clock.slept, ShouldEqual, 0)
+
+			Convey("And taking 5 more waits half a second", func() {
+				b.Wait(5)
+				So(clock.slept, ShouldEqual, 500*time.Millisecond)
+			})
+		})
+		Convey("Taking more than the burst waits for the debt to be repaid", func() {
+			b.Wait(30)
+			So(clock.slept, ShouldEqual, 2*time.Second)
+		})
+		Convey("TryTake fails without taking tokens when there are not enough", func() {
+			So(b.TryTake(8), ShouldBeTrue)
+			So(b.TryTake(8), ShouldBeFalse)
+			So(b.TryTake(2), ShouldBeTrue)
+		})
+		Convey("The bucket refills over time, up to its burst", func() {
+			b.Wait(10)
+			clock.now = clock.now.Add(time.Hour)
+			So(b.TryTake(10), ShouldBeTrue)
+			So(b.TryTake(1), ShouldBeFalse)
+		})
+	})
+}
+
+func TestShardLimiter(t *testing.T) {
+	Convey("Given a limiter on a stream with two shards", t, func() {
+		server := kinesistest.NewServer()
+		defer server.Close()
+		ks := KinesisService{Endpoint: server.URL}
+		stream, _ := ks.CreateStream("foo", 2)
+
+		limiter := &ShardLimiter{Stream: &stream, RecordsPerSecond: 1}
+
+		Convey("Each shard gets its own bucket", func() {
+			So(limiter.Wait("", "0", 10), ShouldBeNil)
+			So(limiter.Wait("", MaxHashKey.String(), 10), ShouldBeNil)
+			So(len(limiter.buckets), ShouldEqual, 2)
+			So(server.Requests("DescribeStream"), ShouldEqual, 1)
+		})
+		Convey("A second record for the same shard within a second is slowed down", func() {
+			limiter.Wait("", "0", 10)
+			started := time.Now()
+			limiter.Wait("", "1", 10)
+			So(time.Since(started), ShouldBeGreaterThan, 900*time.Millisecond)
+		})
+		Convey("An invalid explicit hash key returns an error", func() {
+			So(limiter.Wait("a", "not a number", 10), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,349 @@
+package kinesis
+
+import (
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ShardReader reads every record from a shard in order. It remembers the sequence number of the last record
+// it delivered, and when the shard iterator expires it gets a new one that starts right after that record.
+type ShardReader struct {
+	Shard    *Shard
+	Position StartPosition // Where to start reading. Defaults to TrimHorizon.
+	Limit    int           // The most records to ask for in each GetRecords call. 0 uses the service default.
+
+	// Governor spaces out GetRecords calls to stay within the shard's read limits. Share it between readers of the same shard.
+	// With a Governor, throttled reads are retried after a backoff instead of ending the reader, up to Governor.MaxThrottles in a row.
+	Governor *ReadGovernor
+
+	// StopAtLatest closes the record channel once the reader has caught up with the tip of the shard,
+	// instead of waiting for new records.
+	StopAtLatest bool
+
+	// PrefetchRecords and PrefetchBytes bound how far the reader fetches ahead of the records it has delivered.
+	// If either is set, the next GetRecords call is made while earlier records are still being consumed,
+	// so network latency and processing overlap. 0 means no bound on that measure.
+	PrefetchRecords int
+	PrefetchBytes   int
+
+	mu                 sync.Mutex
+	lastSequenceNumber string
+	processing         bool // Set by Process, which advances lastSequenceNumber itself once each record has been handled.
+	millisBehindLatest int64
+	readToEnd          bool // Whether the record channel was closed because the reader finished, rather than by Stop.
+	stop               chan struct{}
+	stopOnce           sync.Once
+}
+
+// LastSequenceNumber returns the sequence number of the last record the reader delivered, or "" if it has not delivered any.
+// Under Process, it is the last record Process has finished with, so a record whose handler failed is read again on resuming.
+func (r *ShardReader) LastSequenceNumber() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastSequenceNumber
+}
+
+// MillisBehindLatest returns how far behind the tip of the shard the reader's last GetRecords call was, in milliseconds.
+func (r *ShardReader) MillisBehindLatest() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.millisBehindLatest
+}
+
+// Lag returns MillisBehindLatest as a time.Duration.
+func (r *ShardReader) Lag() time.Duration {
+	return time.Duration(r.MillisBehindLatest()) * time.Millisecond
+}
+
+// resumePosition is where to get a new shard iterator from: after the last delivered record, or Position if there is none.
+func (r *ShardReader) resumePosition() StartPosition {
+	if last := r.LastSequenceNumber(); last != "" {
+		return AfterSequence(last)
+	}
+	if r.Position.iteratorType == "" {
+		return TrimHorizon()
+	}
+	return r.Position
+}
+
+// errReaderStopped ends fetching and delivery when the reader is stopped.
+var errReaderStopped = errors.New("kinesis: reader stopped")
+
+// Start creates a goroutine that reads records from the shard and sends them over a channel.
+// The record channel is closed when the shard has been closed by a split or merge and every record has been read,
+// when StopAtLatest is set and the reader has caught up, or when Stop is called.
+// Any other error is sent over the error channel and ends the goroutine.
+func (r *ShardReader) Start() (<-chan Record, <-chan error) {
+	c := make(chan Record)
+	errc := make(chan error, 1)
+
+	r.mu.Lock()
+	if r.stop == nil {
+		r.stop = make(chan struct{})
+	}
+	stop := r.stop
+	r.mu.Unlock()
+
+	if r.PrefetchRecords <= 0 && r.PrefetchBytes <= 0 {
+		go func() {
+			err := r.fetch(stop, func(records []Record) bool {
+				return r.deliver(records, c, stop)
+			})
+			r.finish(c, errc, err)
+		}()
+		return c, errc
+	}
+
+	buffer := &prefetchBuffer{maxRecords: r.PrefetchRecords, maxBytes: r.PrefetchBytes, changed: make(chan struct{})}
+	go func() {
+		err := r.fetch(stop, func(records []Record) bool {
+			return buffer.put(records, stop)
+		})
+		buffer.close(err)
+	}()
+	go func() {
+		for {
+			records, err := buffer.take(stop)
+			if records == nil {
+				r.finish(c, errc, err)
+				return
+			}
+			if !r.deliver(records, c, stop) {
+				r.finish(c, errc, errReaderStopped)
+				return
+			}
+		}
+	}()
+	return c, errc
+}
+
+// finish closes the record channel if err is nil or errReaderStopped, and otherwise sends err.
+func (r *ShardReader) finish(c chan Record, errc chan error, err error) {
+	switch err {
+	case nil:
+		r.mu.Lock()
+		r.readToEnd = true
+		r.mu.Unlock()
+		close(c)
+	case errReaderStopped:
+		close(c)
+	default:
+		errc <- err
+	}
+}
+
+// finished reports whether the record channel was closed because the reader finished, rather than by Stop.
+func (r *ShardReader) finished() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.readToEnd
+}
+
+// fetch calls GetRecords until the shard is done, passing each batch to deliver. It returns nil at the end of a closed
+// shard or, with StopAtLatest, once the reader has caught up, and errReaderStopped if deliver returns false or the reader is stopped.
+func (r *ShardReader) fetch(stop <-chan struct{}, deliver func(records []Record) bool) error {
+	shardIterator, err := r.Shard.GetShardIterator(r.resumePosition())
+	if err != nil {
+		return err
+	}
+	// A renewed iterator starts after the last record fetched, which may be ahead of the last one delivered.
+	lastFetched := ""
+	throttles := 0
+
+	for {
+		select {
+		case <-stop:
+			return errReaderStopped
+		default:
+		}
+
+		if r.Governor != nil && !r.Governor.wait(r.Shard, stop) {
+			return errReaderStopped
+		}
+		output, err := r.Shard.stream.Service.GetRecords(shardIterator, r.Limit)
+		if isThrottlingError(err) {
+			recordReadThrottle(r.Shard)
+		}
+		if r.Governor != nil {
+			r.Governor.Observe(r.Shard, len(output.Records), recordBytes(output.Records), err)
+			if isThrottlingError(err) && throttles < r.Governor.maxThrottles() {
+				throttles++
+				continue
+			}
+		}
+
+		if isErrorType(err, "ExpiredIteratorException") {
+			position := r.resumePosition()
+			if lastFetched != "" {
+				position = AfterSequence(lastFetched)
+			}
+			shardIterator, err = r.Shard.GetShardIterator(position)
+			if err == nil {
+				continue
+			}
+		}
+		if err != nil {
+			return err
+		}
+		throttles = 0
+
+		r.mu.Lock()
+		r.millisBehindLatest = output.MillisBehindLatest
+		r.mu.Unlock()
+		recordRead(r.Shard, output)
+
+		if len(output.Records) > 0 {
+			lastFetched = output.Records[len(output.Records)-1].SequenceNumber
+			if !deliver(output.Records) {
+				return errReaderStopped
+			}
+		}
+
+		caughtUp := r.StopAtLatest && len(output.Records) == 0 && output.MillisBehindLatest == 0
+		if output.NextShardIterator == "" || caughtUp {
+			return nil
+		}
+		shardIterator = output.NextShardIterator
+	}
+}
+
+// deliver sends records over c one at a time. It returns false if the reader was stopped first.
+func (r *ShardReader) deliver(records []Record, c chan<- Record, stop <-chan struct{}) bool {
+	for _, record := range records {
+		select {
+		case c <- record:
+			r.mu.Lock()
+			if !r.processing {
+				r.lastSequenceNumber = record.SequenceNumber
+			}
+			r.mu.Unlock()
+		case <-stop:
+			return false
+		}
+	}
+	return true
+}
+
+// prefetchBuffer holds batches that have been fetched but not yet delivered, up to maxRecords records and maxBytes bytes.
+// A batch is always accepted into an empty buffer, so a batch larger than the limits does not stall the reader.
+type prefetchBuffer struct {
+	maxRecords int
+	maxBytes   int
+
+	mu      sync.Mutex
+	batches [][]Record
+	records int
+	bytes   int
+	done    bool
+	err     error
+	changed chan struct{} // Closed and replaced whenever the buffer changes.
+}
+
+// signal wakes everything waiting for the buffer to change. Callers hold b.mu.
+func (b *prefetchBuffer) signal() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// fits reports whether a batch of n records and size bytes can be added now. Callers hold b.mu.
+func (b *prefetchBuffer) fits(n int, size int) bool {
+	if len(b.batches) == 0 {
+		return true
+	}
+	if b.maxRecords > 0 && b.records+n > b.maxRecords {
+		return false
+	}
+	return b.maxBytes <= 0 || b.bytes+size <= b.maxBytes
+}
+
+// put adds a batch, waiting for room. It returns false if stop is closed first.
+func (b *prefetchBuffer) put(records []Record, stop <-chan struct{}) bool {
+	size := recordBytes(records)
+	for {
+		b.mu.Lock()
+		if b.fits(len(records), size) {
+			b.batches = append(b.batches, records)
+			b.records += len(records)
+			b.bytes += size
+			b.signal()
+			b.mu.Unlock()
+			return true
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// take removes the oldest batch, waiting for one. It returns a nil batch and the error fetching ended with once the
+// buffer is closed and empty, or errReaderStopped if stop is closed first.
+func (b *prefetchBuffer) take(stop <-chan struct{}) ([]Record, error) {
+	for {
+		b.mu.Lock()
+		if len(b.batches) > 0 {
+			records := b.batches[0]
+			b.batches = b.batches[1:]
+			b.records -= len(records)
+			b.bytes -= recordBytes(records)
+			b.signal()
+			b.mu.Unlock()
+			return records, nil
+		}
+		if b.done {
+			err := b.err
+			b.mu.Unlock()
+			return nil, err
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-stop:
+			return nil, errReaderStopped
+		}
+	}
+}
+
+// close marks the end of fetching. err is what fetching ended with.
+func (b *prefetchBuffer) close(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done = true
+	b.err = err
+	b.signal()
+}
+
+// Stop ends the reader's goroutine before its next GetRecords call or record delivery.
+func (r *ShardReader) Stop() {
+	r.mu.Lock()
+	if r.stop == nil {
+		r.stop = make(chan struct{})
+	}
+	stop := r.stop
+	r.mu.Unlock()
+
+	r.stopOnce.Do(func() { close(stop) })
+}
+
+// StreamRecords reads the shard from position with a ShardReader. See ShardReader.Start for how the channels behave.
+func (s *Shard) StreamRecords(position StartPosition) (<-chan Record, <-chan error) {
+	r := &ShardReader{Shard: s, Position: position}
+	return r.Start()
+}
+
+// recordBytes is the size of the decoded data in records, which is what Kinesis counts against read limits.
+func recordBytes(records []Record) int {
+	total := 0
+	for _, record := range records {
+		total += base64.StdEncoding.DecodedLen(len(record.Data))
+	}
+	return total
+}
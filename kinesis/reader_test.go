@@ -0,0 +1,222 @@
+package kinesis
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/controlgroup/gaws/kinesis/kinesistest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// expiringShard serves a shard with two records whose first iterator expires after the first record is read.
+type expiringShard struct {
+	mu        sync.Mutex
+	positions []getShardIteratorRequest
+}
+
+func (e *expiringShard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	body, _ := ioutil.ReadAll(r.Body)
+
+	switch r.Header.Get("X-Amz-Target") {
+	case "Kinesis_20131202.GetShardIterator":
+		request := getShardIteratorRequest{}
+		json.Unmarshal(body, &request)
+		e.positions = append(e.positions, request)
+		if request.ShardIteratorType == "AFTER_SEQUENCE_NUMBER" {
+			w.Write([]byte(`{"ShardIterator": "renewed"}`))
+		} else {
+			w.Write([]byte(`{"ShardIterator": "first"}`))
+		}
+	case "Kinesis_20131202.GetRecords":
+		request := getRecordsRequest{}
+		json.Unmarshal(body, &request)
+		switch request.ShardIterator {
+		case "first":
+			w.Write([]byte(`{"MillisBehindLatest": 5000, "NextShardIterator": "expired", "Records": [{"Data": "b25l", "PartitionKey": "a", "SequenceNumber": "1"}]}`))
+		case "expired":
+			b, _ := json.Marshal(kinesisError{Type: "ExpiredIteratorException", Message: "Iterator expired"})
+			w.WriteHeader(400)
+			w.Write(b)
+		case "renewed":
+			w.Write([]byte(`{"MillisBehindLatest": 1000, "Records": [{"Data": "dHdv", "PartitionKey": "a", "SequenceNumber": "2"}]}`))
+		}
+	}
+}
+
+func TestShardReader(t *testing.T) {
+	Convey("Given a shard whose iterator expires partway through", t, func() {
+		shardServer := &expiringShard{}
+		ts := httptest.NewServer(shardServer)
+		ks := KinesisService{Endpoint: ts.URL}
+		testStream := Stream{Name: "foo", Service: &ks}
+		testShard := Shard{ShardId: "TestShard", stream: &testStream}
+
+		reader := &ShardReader{Shard: &testShard, Position: TrimHorizon()}
+		c, errc := reader.Start()
+
+		records := []Record{}
+		for record := range c {
+			records = append(records, record)
+		}
+
+		Convey("Every record is delivered once", func() {
+			So(len(records), ShouldEqual, 2)
+			So(records[0].SequenceNumber, ShouldEqual, "1")
+			So(records[1].SequenceNumber, ShouldEqual, "2")
+		})
+		Convey("The new iterator starts after the last delivered record", func() {
+			So(len(shardServer.positions), ShouldEqual, 2)
+			So(shardServer.positions[0].ShardIteratorType, ShouldEqual, "TRIM_HORIZON")
+			So(shardServer.positions[1].ShardIteratorType, ShouldEqual, "AFTER_SEQUENCE_NUMBER")
+			So(shardServer.positions[1].StartingSequenceNumber, ShouldEqual, "1")
+		})
+		Convey("The reader remembers the last sequence number", func() {
+			So(reader.LastSequenceNumber(), ShouldEqual, "2")
+		})
+		Convey("The reader reports the lag of its last GetRecords call", func() {
+			So(reader.MillisBehindLatest(), ShouldEqual, 1000)
+			So(reader.Lag(), ShouldEqual, time.Second)
+		})
+		Convey("No error is sent", func() {
+			So(len(errc), ShouldEqual, 0)
+		})
+	})
+	Convey("Given a shard on a server that returns errors", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(testHTTP404))
+		ks := KinesisService{Endpoint: ts.URL}
+		testStream := Stream{Name: "foo", Service: &ks}
+		testShard := Shard{ShardId: "TestShard", stream: &testStream}
+
+		_, errc := testShard.StreamRecords(Latest())
+
+		Convey("The error is sent over the error channel", func() {
+			So(<-errc, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestStoppingShardReader(t *testing.T) {
+	Convey("Given a governed shard that has no records", t, func() {
+		server := kinesistest.NewServer()
+		defer server.Close()
+		ks := KinesisService{Endpoint: server.URL}
+		stream, _ := ks.CreateStream("foo", 1)
+		shards, _ := stream.Shards()
+		governor := &ReadGovernor{IdleDelay: time.Minute}
+
+		// stopWhileWaiting starts reader, stops it once it is waiting out the governor's IdleDelay
+		// and returns how long the record channel took to close.
+		stopWhileWaiting := func(reader *ShardReader) time.Duration {
+			records, _ := reader.Start()
+			time.Sleep(20 * time.Millisecond)
+			started := time.Now()
+			reader.Stop()
+			for range records {
+			}
+			return time.Since(started)
+		}
+
+		Convey("Stop closes the record channel without waiting out the governor", func() {
+			reader := &ShardReader{Shard: &shards[0], Governor: governor}
+			So(stopWhileWaiting(reader), ShouldBeLessThan, time.Second)
+			So(reader.finished(), ShouldBeFalse)
+		})
+		Convey("Stop closes the record channel of a prefetching reader", func() {
+			reader := &ShardReader{Shard: &shards[0], Governor: governor, PrefetchRecords: 10}
+			So(stopWhileWaiting(reader), ShouldBeLessThan, time.Second)
+		})
+	})
+}
+
+func TestPrefetchingShardReader(t *testing.T) {
+	Convey("Given a shard with five records read one per call", t, func() {
+		server := kinesistest.NewServer()
+		defer server.Close()
+		ks := KinesisService{Endpoint: server.URL}
+		stream, _ := ks.CreateStream("foo", 1)
+		for i := 0; i < 5; i++ {
+			stream.PutRecord("a", []byte{byte('0' + i)})
+		}
+		shards, _ := stream.Shards()
+
+		reader := &ShardReader{Shard: &shards[0], Limit: 1, StopAtLatest: true, PrefetchRecords: 2}
+		c, errc := reader.Start()
+		defer reader.Stop()
+
+		readRest := func(first Record) string {
+			data := first.Data
+			for record := range c {
+				data += record.Data
+			}
+			return data
+		}
+
+		Convey("Records are fetched ahead of a slow consumer, up to the bound", func() {
+			first := <-c
+			time.Sleep(50 * time.Millisecond)
+			// One delivered, one waiting to be delivered, two in the buffer and one waiting for room.
+			So(server.Requests("GetRecords"), ShouldEqual, 5)
+
+			Convey("And every record is still delivered in order", func() {
+				So(readRest(first), ShouldEqual, "MA==MQ==Mg==Mw==NA==")
+				So(reader.LastSequenceNumber(), ShouldNotEqual, "")
+				So(len(errc), ShouldEqual, 0)
+			})
+		})
+		Convey("An expired iterator is renewed after the last record fetched", func() {
+			first := <-c
+			time.Sleep(50 * time.Millisecond)
+			server.ExpireIterators()
+			So(readRest(first), ShouldEqual, "MA==MQ==Mg==Mw==NA==")
+		})
+		Convey("Stopping the reader ends both goroutines", func() {
+			<-c
+			reader.Stop()
+			time.Sleep(20 * time.Millisecond)
+			requests := server.Requests("GetRecords")
+			time.Sleep(20 * time.Millisecond)
+			So(server.Requests("GetRecords"), ShouldEqual, requests)
+		})
+	})
+	Convey("Given a prefetch buffer bounded by bytes", t, func() {
+		buffer := &prefetchBuffer{maxBytes: 4, changed: make(chan struct{})}
+		stop := make(chan struct{})
+		batch := []Record{{Data: "b25l"}} // 3 bytes
+
+		Convey("A batch always fits in the empty buffer", func() {
+			So(buffer.put([]Record{{Data: "b25lb25l"}}, stop), ShouldBeTrue)
+		})
+		Convey("A batch that would go over the bound waits for room", func() {
+			buffer.put(batch, stop)
+			put := make(chan bool)
+			go func() { put <- buffer.put(batch, stop) }()
+
+			select {
+			case <-put:
+				t.Error("the second batch should have waited")
+			case <-time.After(20 * time.Millisecond):
+			}
+			records, _ := buffer.take(stop)
+			So(len(records), ShouldEqual, 1)
+			So(<-put, ShouldBeTrue)
+		})
+		Convey("Take returns the error fetching ended with once the buffer is empty", func() {
+			buffer.put(batch, stop)
+			buffer.close(errReaderStopped)
+			records, err := buffer.take(stop)
+			So(len(records), ShouldEqual, 1)
+			So(err, ShouldBeNil)
+			records, err = buffer.take(stop)
+			So(records, ShouldBeNil)
+			So(err, ShouldEqual, errReaderStopped)
+		})
+	})
+}
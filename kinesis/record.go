@@ -2,10 +2,44 @@ package kinesis
 
 import (
 	"encoding/base64"
+	"time"
 )
 
-// Bytes decodes the data in a record and returns it as []byte
+// Bytes decodes the data in a record and returns it as []byte. Data put by a Stream with a Compressor is decompressed.
 func (r *Record) Bytes() ([]byte, error) {
 	result, err := base64.StdEncoding.DecodeString(r.Data)
-	return result, err
+	if err != nil {
+		return result, err
+	}
+	return Decompress(result)
+}
+
+// ArrivalTime returns ApproximateArrivalTimestamp as a time.Time. It is the zero time if the service did not send one.
+func (r *Record) ArrivalTime() time.Time {
+	return epochSecondsTime(r.ApproximateArrivalTimestamp)
+}
+
+// epochSecondsTime converts a timestamp in seconds since the epoch, as Kinesis sends them, to a time.Time.
+// 0 is the zero time.
+func epochSecondsTime(timestamp float64) time.Time {
+	if timestamp == 0 {
+		return time.Time{}
+	}
+	seconds := int64(timestamp)
+	nanoseconds := int64((timestamp - float64(seconds)) * float64(time.Second))
+	return time.Unix(seconds, nanoseconds)
+}
+
+// Decode decodes the data in a record into v with DefaultCodec.
+func (r *Record) Decode(v interface{}) error {
+	return r.DecodeWith(DefaultCodec, v)
+}
+
+// DecodeWith decodes the data in a record into v with codec.
+func (r *Record) DecodeWith(codec Codec, v interface{}) error {
+	data, err := r.Bytes()
+	if err != nil {
+		return err
+	}
+	return codec.Unmarshal(data, v)
 }
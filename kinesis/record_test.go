@@ -25,3 +25,18 @@ func TestBytes(t *testing.T) {
 		})
 	})
 }
+
+func TestArrivalTime(t *testing.T) {
+	Convey("When I use ArrivalTime() on a record with an ApproximateArrivalTimestamp", t, func() {
+		r := Record{ApproximateArrivalTimestamp: 1441215410.5}
+		Convey("The result is the same instant", func() {
+			So(r.ArrivalTime().UnixNano(), ShouldEqual, int64(1441215410500000000))
+		})
+	})
+	Convey("When I use ArrivalTime() on a record without one", t, func() {
+		r := Record{}
+		Convey("The result is the zero time", func() {
+			So(r.ArrivalTime().IsZero(), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,252 @@
+package kinesis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// StatusPollInterval is how long to wait between DescribeStreamSummary calls while waiting for a stream to become ACTIVE.
+var StatusPollInterval = 10 * time.Second
+
+// StatusPollTimeout is how long to wait for a stream to become ACTIVE before giving up.
+var StatusPollTimeout = 10 * time.Minute
+
+// ErrStreamNotActive is returned when a stream does not become ACTIVE within StatusPollTimeout.
+var ErrStreamNotActive = errors.New("kinesis: timed out waiting for stream to become ACTIVE")
+
+type updateShardCountRequest struct {
+	ScalingType      string
+	StreamName       string
+	TargetShardCount int
+}
+
+// UpdateShardCount uniformly scales the stream to targetShardCount open shards.
+// See http://docs.aws.amazon.com/kinesis/latest/APIReference/API_UpdateShardCount.html for more details.
+func (s *Stream) UpdateShardCount(targetShardCount int) error {
+
+	body := updateShardCountRequest{StreamName: s.Name, TargetShardCount: targetShardCount, ScalingType: "UNIFORM_SCALING"}
+	bodyAsJson, err := json.Marshal(body)
+
+	req := s.Service.request()
+	req.Body = bodyAsJson
+	req.Headers["X-Amz-Target"] = "Kinesis_20131202.UpdateShardCount"
+
+	_, err = req.Do()
+	return err
+}
+
+// WaitForActive polls the stream every StatusPollInterval until its StreamStatus is ACTIVE. It returns ErrStreamNotActive after StatusPollTimeout.
+func (s *Stream) WaitForActive() error {
+	deadline := time.Now().Add(StatusPollTimeout)
+
+	for {
+		summary, err := s.DescribeSummary()
+		if err != nil {
+			return err
+		}
+		if summary.StreamStatus == "ACTIVE" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrStreamNotActive
+		}
+		time.Sleep(StatusPollInterval)
+	}
+}
+
+// midpointHashKey returns the hash key that divides the range start through end into two halves.
+func midpointHashKey(start *big.Int, end *big.Int) *big.Int {
+	width := new(big.Int).Sub(end, start)
+	width.Add(width, big.NewInt(1))
+	return width.Rsh(width, 1).Add(width, start)
+}
+
+// SplitEvenly splits the shard into two shards with hash key ranges of equal size.
+func (s *Shard) SplitEvenly() error {
+	start, end, err := s.HashKeyBounds()
+	if err != nil {
+		return err
+	}
+	if start.Cmp(end) == 0 {
+		return fmt.Errorf("kinesis: shard %v covers a single hash key and cannot be split", s.ShardId)
+	}
+	return s.stream.SplitShard(s.ShardId, midpointHashKey(start, end).String())
+}
+
+// MergeWith merges the shard with adjacent, which must be the shard whose hash key range starts right after this one ends.
+func (s *Shard) MergeWith(adjacent *Shard) error {
+	return s.stream.MergeShards(s.ShardId, adjacent.ShardId)
+}
+
+// ReshardOperation is the kind of change made in a ReshardStep.
+type ReshardOperation int
+
+const (
+	SplitOperation ReshardOperation = iota // Split one shard in two.
+	MergeOperation                         // Merge two adjacent shards into one.
+)
+
+// String returns the name of the operation.
+func (o ReshardOperation) String() string {
+	if o == MergeOperation {
+		return "merge"
+	}
+	return "split"
+}
+
+// ReshardStep is a single split or merge in a resharding plan. Shards are identified by their starting hash key,
+// because the IDs of shards created by earlier steps are not known until those steps run.
+type ReshardStep struct {
+	Operation               ReshardOperation
+	StartingHashKey         string // The shard to split, or the lower of the two shards to merge.
+	NewStartingHashKey      string // For splits, the starting hash key of the new upper shard.
+	AdjacentStartingHashKey string // For merges, the upper of the two shards to merge.
+}
+
+// hashKeySpan is the hash key range of a shard while a plan is being built.
+type hashKeySpan struct {
+	start *big.Int
+	end   *big.Int
+}
+
+func (h hashKeySpan) width() *big.Int {
+	return new(big.Int).Sub(h.end, h.start)
+}
+
+// PlanReshard returns the splits and merges that take the open shards in shards to targetShardCount open shards.
+// It splits the widest shard or merges the narrowest adjacent pair at each step, which keeps the hash key space evenly divided.
+func PlanReshard(shards []Shard, targetShardCount int) ([]ReshardStep, error) {
+	if targetShardCount < 1 {
+		return []ReshardStep{}, fmt.Errorf("kinesis: target shard count must be at least 1, got %v", targetShardCount)
+	}
+
+	spans := []hashKeySpan{}
+	for _, shard := range shards {
+		if !shard.IsOpen() {
+			continue
+		}
+		start, end, err := shard.HashKeyBounds()
+		if err != nil {
+			return []ReshardStep{}, err
+		}
+		spans = append(spans, hashKeySpan{start: start, end: end})
+	}
+	if len(spans) == 0 {
+		return []ReshardStep{}, errors.New("kinesis: stream has no open shards")
+	}
+	sort.Sort(byStartingHashKey(spans))
+
+	steps := []ReshardStep{}
+
+	for len(spans) < targetShardCount {
+		widest := 0
+		for i := range spans {
+			if spans[i].width().Cmp(spans[widest].width()) > 0 {
+				widest = i
+			}
+		}
+		span := spans[widest]
+		if span.start.Cmp(span.end) == 0 {
+			return []ReshardStep{}, errors.New("kinesis: shards are too narrow to split further")
+		}
+
+		mid := midpointHashKey(span.start, span.end)
+		steps = append(steps, ReshardStep{Operation: SplitOperation, StartingHashKey: span.start.String(), NewStartingHashKey: mid.String()})
+
+		lower := hashKeySpan{start: span.start, end: new(big.Int).Sub(mid, big.NewInt(1))}
+		upper := hashKeySpan{start: mid, end: span.end}
+		spans = append(spans[:widest], append([]hashKeySpan{lower, upper}, spans[widest+1:]...)...)
+	}
+
+	for len(spans) > targetShardCount {
+		narrowest := 0
+		for i := 0; i < len(spans)-1; i++ {
+			if pairWidth(spans, i).Cmp(pairWidth(spans, narrowest)) < 0 {
+				narrowest = i
+			}
+		}
+		lower, upper := spans[narrowest], spans[narrowest+1]
+		steps = append(steps, ReshardStep{Operation: MergeOperation, StartingHashKey: lower.start.String(), AdjacentStartingHashKey: upper.start.String()})
+
+		merged := hashKeySpan{start: lower.start, end: upper.end}
+		spans = append(spans[:narrowest], append([]hashKeySpan{merged}, spans[narrowest+2:]...)...)
+	}
+
+	return steps, nil
+}
+
+// pairWidth is the combined width of spans[i] and spans[i+1].
+func pairWidth(spans []hashKeySpan, i int) *big.Int {
+	return new(big.Int).Sub(spans[i+1].end, spans[i].start)
+}
+
+type byStartingHashKey []hashKeySpan
+
+func (b byStartingHashKey) Len() int           { return len(b) }
+func (b byStartingHashKey) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byStartingHashKey) Less(i, j int) bool { return b[i].start.Cmp(b[j].start) < 0 }
+
+// openShardStartingAt returns the open shard whose hash key range starts at startingHashKey.
+func openShardStartingAt(shards []Shard, startingHashKey string) (Shard, error) {
+	for _, shard := range shards {
+		if shard.IsOpen() && shard.HashKeyRange.StartingHashKey == startingHashKey {
+			return shard, nil
+		}
+	}
+	return Shard{}, fmt.Errorf("kinesis: no open shard starts at hash key %v", startingHashKey)
+}
+
+// Reshard splits and merges the stream's open shards until there are targetShardCount of them.
+// It plans the steps with PlanReshard and waits for the stream to become ACTIVE before and after each one.
+func (s *Stream) Reshard(targetShardCount int) error {
+	if err := s.WaitForActive(); err != nil {
+		return err
+	}
+
+	shards, err := s.Shards()
+	if err != nil {
+		return err
+	}
+
+	steps, err := PlanReshard(shards, targetShardCount)
+	if err != nil {
+		return err
+	}
+
+	for _, step := range steps {
+		if err := s.applyReshardStep(step); err != nil {
+			return err
+		}
+		if err := s.WaitForActive(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyReshardStep looks up the shards a step refers to and makes the SplitShard or MergeShards call.
+func (s *Stream) applyReshardStep(step ReshardStep) error {
+	shards, err := s.Shards()
+	if err != nil {
+		return err
+	}
+
+	shard, err := openShardStartingAt(shards, step.StartingHashKey)
+	if err != nil {
+		return err
+	}
+
+	if step.Operation == SplitOperation {
+		return s.SplitShard(shard.ShardId, step.NewStartingHashKey)
+	}
+
+	adjacent, err := openShardStartingAt(shards, step.AdjacentStartingHashKey)
+	if err != nil {
+		return err
+	}
+	return s.MergeShards(shard.ShardId, adjacent.ShardId)
+}
@@ -0,0 +1,170 @@
+package kinesis
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// evenShards returns count open shards that divide the hash key space as evenly as PlanReshard would.
+func evenShards(count int) []Shard {
+	shards := []Shard{{ShardId: "shardId-000000000000"}}
+	shards[0].HashKeyRange.StartingHashKey = "0"
+	shards[0].HashKeyRange.EndingHashKey = MaxHashKey.String()
+
+	steps, _ := PlanReshard(shards, count)
+	for _, step := range steps {
+		for i := range shards {
+			if shards[i].HashKeyRange.StartingHashKey != step.StartingHashKey {
+				continue
+			}
+			upper := Shard{ShardId: step.NewStartingHashKey}
+			upper.HashKeyRange.StartingHashKey = step.NewStartingHashKey
+			upper.HashKeyRange.EndingHashKey = shards[i].HashKeyRange.EndingHashKey
+			newStart, _ := parseHashKey(step.NewStartingHashKey)
+			shards[i].HashKeyRange.EndingHashKey = newStart.Sub(newStart, big.NewInt(1)).String()
+			shards = append(shards, upper)
+			break
+		}
+	}
+	return shards
+}
+
+func TestPlanReshard(t *testing.T) {
+	Convey("Given a single open shard covering every hash key", t, func() {
+		shards := evenShards(1)
+
+		Convey("Planning for two shards splits it in half", func() {
+			steps, err := PlanReshard(shards, 2)
+			So(err, ShouldBeNil)
+			So(steps, ShouldResemble, []ReshardStep{
+				{Operation: SplitOperation, StartingHashKey: "0", NewStartingHashKey: "170141183460469231731687303715884105728"},
+			})
+		})
+		Convey("Planning for one shard does nothing", func() {
+			steps, err := PlanReshard(shards, 1)
+			So(err, ShouldBeNil)
+			So(steps, ShouldBeEmpty)
+		})
+		Convey("Planning for zero shards returns an error", func() {
+			_, err := PlanReshard(shards, 0)
+			So(err, ShouldNotBeNil)
+		})
+	})
+	Convey("Given four evenly divided open shards", t, func() {
+		shards := evenShards(4)
+		So(len(shards), ShouldEqual, 4)
+
+		Convey("Planning for two shards merges adjacent pairs", func() {
+			steps, err := PlanReshard(shards, 2)
+			So(err, ShouldBeNil)
+			So(len(steps), ShouldEqual, 2)
+			for _, step := range steps {
+				So(step.Operation, ShouldEqual, MergeOperation)
+			}
+		})
+		Convey("Planning for eight shards splits every shard once", func() {
+			steps, err := PlanReshard(shards, 8)
+			So(err, ShouldBeNil)
+			split := map[string]bool{}
+			for _, step := range steps {
+				So(step.Operation, ShouldEqual, SplitOperation)
+				split[step.StartingHashKey] = true
+			}
+			So(len(split), ShouldEqual, 4)
+		})
+	})
+	Convey("Given shards that are all closed", t, func() {
+		shards := evenShards(1)
+		shards[0].SequenceNumberRange.EndingSequenceNumber = "1"
+
+		Convey("Planning returns an error", func() {
+			_, err := PlanReshard(shards, 2)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestUpdateShardCount(t *testing.T) {
+	Convey("Given a Stream and a Server that responds with success to every request", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(testHTTP200))
+		ks := KinesisService{Endpoint: ts.URL}
+		testStream := Stream{Name: "foo", Service: &ks}
+
+		Convey("There is no error when I call Stream.UpdateShardCount()", func() {
+			So(testStream.UpdateShardCount(4), ShouldBeNil)
+		})
+	})
+	Convey("Given a Stream and a Server that responds with an error to every request", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(testHTTP404))
+		ks := KinesisService{Endpoint: ts.URL}
+		testStream := Stream{Name: "foo", Service: &ks}
+
+		Convey("There is an error when I call Stream.UpdateShardCount()", func() {
+			So(testStream.UpdateShardCount(4), ShouldNotBeNil)
+		})
+	})
+}
+
+// reshardRecorder answers DescribeStream with testStreamDescription and DescribeStreamSummary with an ACTIVE summary,
+// and records every other request body.
+type reshardRecorder struct {
+	targets []string
+	bodies  []map[string]string
+}
+
+func (rr *reshardRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.Header.Get("X-Amz-Target")
+	if target == "Kinesis_20131202.DescribeStream" {
+		testDescribeStreamSuccess(w, r)
+		return
+	}
+	if target == "Kinesis_20131202.DescribeStreamSummary" {
+		testDescribeStreamSummarySuccess(w, r)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+	decoded := map[string]string{}
+	json.Unmarshal(body, &decoded)
+
+	rr.targets = append(rr.targets, target)
+	rr.bodies = append(rr.bodies, decoded)
+	w.Write([]byte("{}"))
+}
+
+func TestReshard(t *testing.T) {
+	Convey("Given an ACTIVE stream with two open shards", t, func() {
+		StatusPollInterval = time.Millisecond
+		rr := &reshardRecorder{}
+		ts := httptest.NewServer(rr)
+		ks := KinesisService{Endpoint: ts.URL}
+		testStream := Stream{Name: "foo", Service: &ks}
+
+		Convey("Resharding to three shards splits the widest open shard", func() {
+			err := testStream.Reshard(3)
+			So(err, ShouldBeNil)
+			So(rr.targets, ShouldResemble, []string{"Kinesis_20131202.SplitShard"})
+			So(rr.bodies[0]["ShardToSplit"], ShouldEqual, "shardId-000000000002")
+		})
+		Convey("Resharding to one shard merges the two open shards", func() {
+			err := testStream.Reshard(1)
+			So(err, ShouldBeNil)
+			So(rr.targets, ShouldResemble, []string{"Kinesis_20131202.MergeShards"})
+			So(rr.bodies[0]["ShardToMerge"], ShouldEqual, "shardId-000000000001")
+			So(rr.bodies[0]["AdjacentShardToMerge"], ShouldEqual, "shardId-000000000002")
+		})
+		Convey("SplitEvenly splits a shard at the middle of its range", func() {
+			shards, _ := testStream.OpenShards()
+			err := shards[1].SplitEvenly()
+			So(err, ShouldBeNil)
+			So(rr.bodies[0]["NewStartingHashKey"], ShouldEqual, "283568639100782052886145506193140176213")
+		})
+	})
+}
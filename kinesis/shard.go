@@ -2,6 +2,7 @@ package kinesis
 
 import (
 	"encoding/json"
+	"time"
 )
 
 // Shard is a shard in a Kinesis stream.
@@ -29,15 +30,24 @@ type getShardIteratorRequest struct {
 	ShardIteratorType      string
 	StartingSequenceNumber string `json:",omitempty"`
 	StreamName             string
+	Timestamp              *float64 `json:",omitempty"`
 }
 
-// GetShardIterator gets a shard iterator from the shard. It takes a type, which is one of: AT_SEQUENCE_NUMBER, AFTER_SEQUENCE_NUMBER, TRIM_HORIZON, or LATEST and an optional sequence number to start on.
+// GetShardIterator gets a shard iterator from the shard that starts reading at position.
 // See http://docs.aws.amazon.com/kinesis/latest/APIReference/API_GetShardIterator.html for more details.
-func (s *Shard) GetShardIterator(shardIteratorType string, startingSequenceNumber string) (string, error) {
+func (s *Shard) GetShardIterator(position StartPosition) (string, error) {
 
 	result := getShardIteratorResponse{}
 
-	body := getShardIteratorRequest{ShardId: s.ShardId, ShardIteratorType: shardIteratorType, StartingSequenceNumber: startingSequenceNumber, StreamName: s.stream.Name}
+	if err := position.Validate(); err != nil {
+		return "", err
+	}
+
+	body := getShardIteratorRequest{ShardId: s.ShardId, ShardIteratorType: string(position.iteratorType), StartingSequenceNumber: position.sequenceNumber, StreamName: s.stream.Name}
+	if position.iteratorType == atTimestamp {
+		seconds := float64(position.timestamp.UnixNano()) / float64(time.Second)
+		body.Timestamp = &seconds
+	}
 
 	bodyAsJson, err := json.Marshal(body)
 	req := s.stream.Service.request()
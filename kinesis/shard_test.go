@@ -25,7 +25,7 @@ func TestGetShardIterator(t *testing.T) {
 		testShard := Shard{ShardId: "TestShard", stream: &testStream}
 
 		Convey("Using GetShardIterator with a ShardIteratorType and StartingSequenceNumber", func() {
-			result, err := testShard.GetShardIterator("LATEST", "12345")
+			result, err := testShard.GetShardIterator(AtSequence("12345"))
 			Convey("Does not return an error", func() {
 				So(err, ShouldBeNil)
 			})
@@ -40,7 +40,7 @@ func TestGetShardIterator(t *testing.T) {
 		testStream := Stream{Name: "foo", Service: &ks}
 
 		testShard := Shard{ShardId: "TestShard", stream: &testStream}
-		resp, err := testShard.GetShardIterator("LATEST", "12345")
+		resp, err := testShard.GetShardIterator(Latest())
 		
 		Convey("It should return an error", func() {
 			So(err, ShouldNotBeNil)
@@ -55,7 +55,7 @@ func TestGetShardIterator(t *testing.T) {
 		testStream := Stream{Name: "foo", Service: &ks}
 
 		testShard := Shard{ShardId: "TestShard", stream: &testStream}
-		resp, err := testShard.GetShardIterator("LATEST", "12345")
+		resp, err := testShard.GetShardIterator(Latest())
 		
 		Convey("It should return an error", func() {
 			So(err, ShouldNotBeNil)
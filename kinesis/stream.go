@@ -3,15 +3,33 @@ package kinesis
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/controlgroup/gaws"
 )
 
 // PutRecord puts data on a Kinesis stream. It returns an error if it fails.
+// If partitionKey is empty, the stream's Keyer chooses one.
 // See http://docs.aws.amazon.com/kinesis/latest/APIReference/API_PutRecord.html for more details.
 func (s *Stream) PutRecord(partitionKey string, data []byte) error {
+	partitionKey, explicitHashKey, err := s.assignKey(partitionKey, "", data)
+	if err != nil {
+		return err
+	}
+	return s.putRecord(partitionKey, explicitHashKey, data)
+}
 
+// putRecord puts data with keys that have already been assigned.
+func (s *Stream) putRecord(partitionKey string, explicitHashKey string, data []byte) error {
+	data, err := s.compress(data)
+	if err != nil {
+		return err
+	}
 	encodedData := base64.StdEncoding.EncodeToString(data)
 
-	body := putRecordRequest{StreamName: s.Name, Data: encodedData, PartitionKey: partitionKey}
+	body := putRecordRequest{StreamName: s.Name, Data: encodedData, ExplicitHashKey: explicitHashKey, PartitionKey: partitionKey}
 	bodyAsJson, err := json.Marshal(body)
 
 	req := s.Service.request()
@@ -19,18 +37,195 @@ func (s *Stream) PutRecord(partitionKey string, data []byte) error {
 	req.Headers["X-Amz-Target"] = "Kinesis_20131202.PutRecord"
 
 	_, err = req.Do()
+	if err == nil {
+		recordPut(s, len(data))
+	} else if isThrottlingError(err) {
+		recordPutThrottle(s, 1)
+	}
 
 	return err
 }
 
+// PutRecordsEntry is one record in a PutRecords call.
+type PutRecordsEntry struct {
+	Data            []byte // The data to put. It is compressed and Base64 encoded for you.
+	ExplicitHashKey string // Optional hash key that places the record on a shard in place of the hash of PartitionKey.
+	PartitionKey    string
+}
+
+type putRecordsRequestEntry struct {
+	Data            string
+	ExplicitHashKey string `json:",omitempty"`
+	PartitionKey    string
+}
+
+type putRecordsRequest struct {
+	Records    []putRecordsRequestEntry
+	StreamName string
+}
+
+// PutRecordsResultEntry is the result of putting one record with PutRecords. ErrorCode is set if that record failed.
+type PutRecordsResultEntry struct {
+	ErrorCode      string
+	ErrorMessage   string
+	SequenceNumber string
+	ShardId        string
+}
+
+// PutRecordsOutput is returned by PutRecords. Records is in the same order as the entries that were put.
+type PutRecordsOutput struct {
+	FailedRecordCount int
+	Records           []PutRecordsResultEntry
+}
+
+// PutRecords puts up to 500 records on a Kinesis stream in one request. Individual records can fail even when
+// the request succeeds; check FailedRecordCount and the ErrorCode of each result.
+// Entries without a partition key are given one by the stream's Keyer.
+// See http://docs.aws.amazon.com/kinesis/latest/APIReference/API_PutRecords.html for more details.
+func (s *Stream) PutRecords(entries []PutRecordsEntry) (PutRecordsOutput, error) {
+	result := PutRecordsOutput{}
+
+	body := putRecordsRequest{StreamName: s.Name, Records: make([]putRecordsRequestEntry, len(entries))}
+	sizes := make([]int, len(entries))
+	for i, entry := range entries {
+		partitionKey, explicitHashKey, err := s.assignKey(entry.PartitionKey, entry.ExplicitHashKey, entry.Data)
+		if err != nil {
+			return PutRecordsOutput{}, err
+		}
+		data, err := s.compress(entry.Data)
+		if err != nil {
+			return PutRecordsOutput{}, err
+		}
+		body.Records[i] = putRecordsRequestEntry{Data: base64.StdEncoding.EncodeToString(data), ExplicitHashKey: explicitHashKey, PartitionKey: partitionKey}
+		sizes[i] = len(data)
+	}
+	bodyAsJson, err := json.Marshal(body)
+
+	req := s.Service.request()
+	req.Body = bodyAsJson
+	req.Headers["X-Amz-Target"] = "Kinesis_20131202.PutRecords"
+
+	resp, err := req.Do()
+	if err != nil {
+		if isThrottlingError(err) {
+			recordPutThrottle(s, len(entries))
+		}
+		return PutRecordsOutput{}, err
+	}
+
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return PutRecordsOutput{}, err
+	}
+	recordPutBatch(s, sizes, result)
+	return result, nil
+}
+
+// MaxPutRecordsEntries is the most records PutRecords accepts in one call.
+const MaxPutRecordsEntries = 500
+
+// ErrPutRecordsFailed is returned when some records could not be put after gaws.MaxTries attempts.
+var ErrPutRecordsFailed = errors.New("kinesis: some records could not be put")
+
+// ErrRecordTooLarge is returned when a record is larger than MaxRecordBytes, data and partition key together.
+var ErrRecordTooLarge = errors.New("kinesis: record is larger than MaxRecordBytes")
+
+// batchEntries splits entries into batches that PutRecords accepts, each of no more than MaxPutRecordsEntries records
+// and MaxPutRecordsBytes. It returns ErrRecordTooLarge if an entry is too large to put at all.
+func batchEntries(entries []PutRecordsEntry) ([][]PutRecordsEntry, error) {
+	batches := [][]PutRecordsEntry{}
+	batch := []PutRecordsEntry{}
+	batchBytes := 0
+	for _, entry := range entries {
+		size := entrySize(entry)
+		if size > MaxRecordBytes {
+			return nil, ErrRecordTooLarge
+		}
+		if len(batch) >= MaxPutRecordsEntries || batchBytes+size > MaxPutRecordsBytes {
+			batches = append(batches, batch)
+			batch = []PutRecordsEntry{}
+			batchBytes = 0
+		}
+		batch = append(batch, entry)
+		batchBytes += size
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches, nil
+}
+
+// putAll puts entries with PutRecords, retrying the records that fail with an exponential backoff.
+// It returns the number of records put and ErrPutRecordsFailed if any were still failing after gaws.MaxTries attempts.
+func (s *Stream) putAll(entries []PutRecordsEntry) (int, error) {
+	unsent, err := s.putUnsent(entries)
+	return len(entries) - len(unsent), err
+}
+
+// putUnsent is putAll, but returns the entries that were not put, in their original order.
+func (s *Stream) putUnsent(entries []PutRecordsEntry) ([]PutRecordsEntry, error) {
+	for try := 1; len(entries) > 0; try++ {
+		output, err := s.PutRecords(entries)
+		if err != nil {
+			return entries, err
+		}
+
+		failed := []PutRecordsEntry{}
+		for i, result := range output.Records {
+			if result.ErrorCode != "" && i < len(entries) {
+				failed = append(failed, entries[i])
+			}
+		}
+		entries = failed
+
+		if len(entries) == 0 {
+			break
+		}
+		if try >= gaws.MaxTries {
+			return entries, ErrPutRecordsFailed
+		}
+		time.Sleep(time.Duration(100*math.Pow(2.0, float64(try))) * time.Millisecond)
+	}
+	return nil, nil
+}
+
+// entrySize is the size of an entry as Kinesis counts it against its limits.
+func entrySize(entry PutRecordsEntry) int {
+	return len(entry.Data) + len(entry.PartitionKey)
+}
+
+// PutValue encodes v with the stream's Codec and puts it on the stream with PutRecord.
+func (s *Stream) PutValue(partitionKey string, v interface{}) error {
+	data, err := s.codec().Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.PutRecord(partitionKey, data)
+}
+
+// codec returns the stream's Codec, or DefaultCodec if it has none.
+func (s *Stream) codec() Codec {
+	if s.Codec == nil {
+		return DefaultCodec
+	}
+	return s.Codec
+}
+
+type deleteStreamRequest struct {
+	StreamName string
+}
+
 // Delete deletes a stream. It is calling the DeleteStream API call.
 // See http://docs.aws.amazon.com/kinesis/latest/APIReference/API_DeleteStream.html for more details.
 func (s *Stream) Delete() error {
-	req := s.Service.request()
+	body := deleteStreamRequest{StreamName: s.Name}
+	bodyAsJson, err := json.Marshal(body)
 
+	req := s.Service.request()
+	req.Body = bodyAsJson
 	req.Headers["X-Amz-Target"] = "Kinesis_20131202.DeleteStream"
 
-	_, err := req.Do()
+	_, err = req.Do()
 
 	return err
 }
@@ -57,9 +252,14 @@ type streamDescriptionRequest struct {
 // Describe describes a stream. It is calling the DescribeStream API call.
 // See http://docs.aws.amazon.com/kinesis/latest/APIReference/API_DescribeStream.html for more details.
 func (s *Stream) Describe() (StreamDescription, error) {
+	return s.describe("")
+}
+
+// describe calls DescribeStream, listing shards after exclusiveStartShardId if it is set.
+func (s *Stream) describe(exclusiveStartShardId string) (StreamDescription, error) {
 	result := streamDescriptionResult{}
 
-	body := streamDescriptionRequest{StreamName: s.Name}
+	body := streamDescriptionRequest{StreamName: s.Name, ExclusiveStartShardId: exclusiveStartShardId}
 	bodyAsJson, err := json.Marshal(body)
 
 	req := s.Service.request()
@@ -83,6 +283,41 @@ func (s *Stream) Describe() (StreamDescription, error) {
 	return result.StreamDescription, err
 }
 
+// Shards returns every shard in the stream, following HasMoreShards through as many DescribeStream calls as it takes.
+func (s *Stream) Shards() ([]Shard, error) {
+	shards := []Shard{}
+	lastShardId := ""
+
+	for {
+		description, err := s.describe(lastShardId)
+		if err != nil {
+			return []Shard{}, err
+		}
+
+		shards = append(shards, description.Shards...)
+		if !description.HasMoreShards || len(description.Shards) == 0 {
+			return shards, nil
+		}
+		lastShardId = description.Shards[len(description.Shards)-1].ShardId
+	}
+}
+
+// OpenShards returns the shards in the stream that can still receive records.
+func (s *Stream) OpenShards() ([]Shard, error) {
+	shards, err := s.Shards()
+	if err != nil {
+		return []Shard{}, err
+	}
+
+	open := []Shard{}
+	for _, shard := range shards {
+		if shard.IsOpen() {
+			open = append(open, shard)
+		}
+	}
+	return open, nil
+}
+
 type mergeShardsRequest struct {
 	AdjacentShardToMerge string
 	ShardToMerge         string
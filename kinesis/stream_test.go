@@ -32,6 +32,56 @@ func TestPutRecord(t *testing.T) {
 	})
 }
 
+var testPutRecordsResult []byte = []byte(`{
+  "FailedRecordCount": 1,
+  "Records": [
+    {
+      "SequenceNumber": "49543463076548007577105092703039560359975228518395012686",
+      "ShardId": "shardId-000000000000"
+    },
+    {
+      "ErrorCode": "ProvisionedThroughputExceededException",
+      "ErrorMessage": "Rate exceeded for shard shardId-000000000001 in stream exampleStreamName under account 111111111111."
+    }
+  ]
+}`)
+
+func testPutRecordsSuccess(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(200)
+	w.Write(testPutRecordsResult)
+}
+
+func TestPutRecords(t *testing.T) {
+	Convey("Given a test stream on a server that fails one of two records", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(testPutRecordsSuccess))
+		ks := KinesisService{Endpoint: ts.URL}
+		testStream := Stream{Name: "foo", Service: &ks}
+
+		entries := []PutRecordsEntry{{PartitionKey: "a", Data: []byte("one")}, {PartitionKey: "b", Data: []byte("two")}}
+		result, err := testStream.PutRecords(entries)
+
+		Convey("There is no error", func() {
+			So(err, ShouldBeNil)
+		})
+		Convey("The result reports the failed record", func() {
+			So(result.FailedRecordCount, ShouldEqual, 1)
+			So(result.Records[0].ShardId, ShouldEqual, "shardId-000000000000")
+			So(result.Records[1].ErrorCode, ShouldEqual, "ProvisionedThroughputExceededException")
+		})
+	})
+	Convey("Given a test stream on a server that returns errors", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(testHTTP404))
+		ks := KinesisService{Endpoint: ts.URL}
+		testStream := Stream{Name: "foo", Service: &ks}
+
+		_, err := testStream.PutRecords([]PutRecordsEntry{{PartitionKey: "a", Data: []byte("one")}})
+
+		Convey("There is an error", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
 func TestDeleteStream(t *testing.T) {
 	Convey("Given a Stream and a Server that responds with success to every request", t, func() {
 		ts := httptest.NewServer(http.HandlerFunc(testHTTP200))
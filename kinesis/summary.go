@@ -0,0 +1,88 @@
+package kinesis
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EnhancedMetrics lists the shard-level metrics enabled on a stream.
+type EnhancedMetrics struct {
+	ShardLevelMetrics []string
+}
+
+// StreamDescriptionSummary describes a stream without listing its shards.
+type StreamDescriptionSummary struct {
+	ConsumerCount           int    // The number of enhanced fan-out consumers registered with the stream.
+	EncryptionType          string // NONE or KMS.
+	EnhancedMonitoring      []EnhancedMetrics
+	KeyId                   string // The KMS key used to encrypt the stream, if it is encrypted.
+	OpenShardCount          int
+	RetentionPeriodHours    int
+	StreamARN               string
+	StreamCreationTimestamp float64 // When the stream was created, in seconds since the epoch.
+	StreamName              string
+	StreamStatus            string // The status of the stream. May be CREATING, DELETING, ACTIVE, or UPDATING.
+}
+
+// CreationTime returns StreamCreationTimestamp as a time.Time.
+func (d StreamDescriptionSummary) CreationTime() time.Time {
+	return epochSecondsTime(d.StreamCreationTimestamp)
+}
+
+type streamDescriptionSummaryResult struct {
+	StreamDescriptionSummary StreamDescriptionSummary
+}
+
+type streamDescriptionSummaryRequest struct {
+	StreamName string
+}
+
+// DescribeSummary describes a stream without listing its shards, which makes it a cheap way to check its status.
+// See http://docs.aws.amazon.com/kinesis/latest/APIReference/API_DescribeStreamSummary.html for more details.
+func (s *Stream) DescribeSummary() (StreamDescriptionSummary, error) {
+	result := streamDescriptionSummaryResult{}
+
+	body := streamDescriptionSummaryRequest{StreamName: s.Name}
+	bodyAsJson, err := json.Marshal(body)
+
+	req := s.Service.request()
+	req.Body = bodyAsJson
+	req.Headers["X-Amz-Target"] = "Kinesis_20131202.DescribeStreamSummary"
+
+	resp, err := req.Do()
+	if err != nil {
+		return StreamDescriptionSummary{}, err
+	}
+
+	err = json.Unmarshal(resp, &result)
+	return result.StreamDescriptionSummary, err
+}
+
+// AccountLimits are the shard limits of the account in the service's region.
+type AccountLimits struct {
+	OpenShardCount int // The number of open shards in every stream.
+	ShardLimit     int // The most open shards the account may have.
+}
+
+// AvailableShards returns how many more shards can be opened before reaching ShardLimit.
+func (l AccountLimits) AvailableShards() int {
+	return l.ShardLimit - l.OpenShardCount
+}
+
+// DescribeLimits returns the account's shard limit and how many shards are open.
+// See http://docs.aws.amazon.com/kinesis/latest/APIReference/API_DescribeLimits.html for more details.
+func (s *KinesisService) DescribeLimits() (AccountLimits, error) {
+	result := AccountLimits{}
+
+	req := s.request()
+	req.Body = []byte("{}")
+	req.Headers["X-Amz-Target"] = "Kinesis_20131202.DescribeLimits"
+
+	resp, err := req.Do()
+	if err != nil {
+		return AccountLimits{}, err
+	}
+
+	err = json.Unmarshal(resp, &result)
+	return result, err
+}
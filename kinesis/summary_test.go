@@ -0,0 +1,94 @@
+package kinesis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/controlgroup/gaws/kinesis/kinesistest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var testStreamDescriptionSummary = []byte(`{
+  "StreamDescriptionSummary": {
+    "ConsumerCount": 1,
+    "EncryptionType": "KMS",
+    "EnhancedMonitoring": [
+      {
+        "ShardLevelMetrics": ["IncomingBytes"]
+      }
+    ],
+    "KeyId": "alias/aws/kinesis",
+    "OpenShardCount": 3,
+    "RetentionPeriodHours": 24,
+    "StreamARN": "arn:aws:kinesis:us-east-1:052958737983:exampleStreamName",
+    "StreamCreationTimestamp": 1500000000.5,
+    "StreamName": "exampleStreamName",
+    "StreamStatus": "ACTIVE"
+  }
+}`)
+
+func testDescribeStreamSummarySuccess(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(200)
+	w.Write(testStreamDescriptionSummary)
+}
+
+func TestDescribeSummary(t *testing.T) {
+	Convey("When you call stream.DescribeSummary() on a stream with an endpoint that returns a summary", t, func() {
+		ts := httptest.NewServer(http.HandlerFunc(testDescribeStreamSummarySuccess))
+		defer ts.Close()
+		ks := KinesisService{Endpoint: ts.URL}
+		testStream := Stream{Name: "foo", Service: &ks}
+		summary, err := testStream.DescribeSummary()
+
+		Convey("It does not return an error", func() {
+			So(err, ShouldBeNil)
+		})
+		Convey("It returns the summary", func() {
+			So(summary.StreamStatus, ShouldEqual, "ACTIVE")
+			So(summary.OpenShardCount, ShouldEqual, 3)
+			So(summary.RetentionPeriodHours, ShouldEqual, 24)
+			So(summary.EncryptionType, ShouldEqual, "KMS")
+			So(summary.ConsumerCount, ShouldEqual, 1)
+			So(summary.EnhancedMonitoring[0].ShardLevelMetrics, ShouldResemble, []string{"IncomingBytes"})
+			So(summary.CreationTime(), ShouldResemble, time.Unix(1500000000, 500000000))
+		})
+	})
+	Convey("When you call stream.DescribeSummary() on an in-memory stream", t, func() {
+		server := kinesistest.NewServer()
+		defer server.Close()
+		ks := KinesisService{Endpoint: server.URL}
+		stream, _ := ks.CreateStream("foo", 2)
+		summary, err := stream.DescribeSummary()
+
+		Convey("It counts the open shards without listing them", func() {
+			So(err, ShouldBeNil)
+			So(summary.OpenShardCount, ShouldEqual, 2)
+			So(server.Requests("DescribeStream"), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestDescribeLimits(t *testing.T) {
+	Convey("Given an account with a limit of 10 shards and a stream with 4", t, func() {
+		server := kinesistest.NewServer()
+		defer server.Close()
+		server.ShardLimit = 10
+		ks := KinesisService{Endpoint: server.URL}
+		ks.CreateStream("foo", 4)
+
+		limits, err := ks.DescribeLimits()
+
+		Convey("DescribeLimits returns the limit and the open shards", func() {
+			So(err, ShouldBeNil)
+			So(limits.ShardLimit, ShouldEqual, 10)
+			So(limits.OpenShardCount, ShouldEqual, 4)
+			So(limits.AvailableShards(), ShouldEqual, 6)
+		})
+		Convey("Creating a stream that would go over the limit fails", func() {
+			_, err := ks.CreateStream("bar", 7)
+			So(isErrorType(err, "LimitExceededException"), ShouldBeTrue)
+		})
+	})
+}
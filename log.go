@@ -0,0 +1,112 @@
+package gaws
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+)
+
+// LogLevel is how important a log message is.
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota // Every attempt at a request.
+	LevelInfo
+	LevelWarn  // An attempt that failed and will be retried.
+	LevelError // A request that failed for good.
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// Fields are the structured details of a log message, such as the operation and status of a request.
+type Fields map[string]interface{}
+
+// Logger receives log messages from gaws. Implementations must be safe for concurrent use.
+type Logger interface {
+	Log(level LogLevel, message string, fields Fields)
+}
+
+// with returns a copy of the fields with the given key and value pairs added.
+func (f Fields) with(keysAndValues ...interface{}) Fields {
+	result := Fields{}
+	for k, v := range f {
+		result[k] = v
+	}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		result[keysAndValues[i].(string)] = keysAndValues[i+1]
+	}
+	return result
+}
+
+// LoggerFunc adapts a function to a Logger.
+type LoggerFunc func(level LogLevel, message string, fields Fields)
+
+// Log calls f.
+func (f LoggerFunc) Log(level LogLevel, message string, fields Fields) {
+	f(level, message, fields)
+}
+
+// loggerValue holds a Logger in an atomic.Value, which needs every value it stores to have the same type.
+type loggerValue struct {
+	logger Logger
+}
+
+var defaultLogger atomic.Value
+
+// DefaultLogger returns the Logger that receives every log message. It discards them until SetDefaultLogger replaces it.
+func DefaultLogger() Logger {
+	if v, ok := defaultLogger.Load().(loggerValue); ok {
+		return v.logger
+	}
+	return NopLogger{}
+}
+
+// SetDefaultLogger replaces the Logger that receives every log message. nil discards them.
+// It is safe to call while requests are being made.
+func SetDefaultLogger(logger Logger) {
+	if logger == nil {
+		logger = NopLogger{}
+	}
+	defaultLogger.Store(loggerValue{logger: logger})
+}
+
+// NopLogger discards every log message.
+type NopLogger struct{}
+
+func (NopLogger) Log(level LogLevel, message string, fields Fields) {}
+
+// requestIdHeaders are the response headers AWS services put the request ID in.
+var requestIdHeaders = []string{"X-Amzn-Requestid", "X-Amz-Request-Id"}
+
+// responseFields returns the fields that describe an attempt's response: its status, request ID and, for an error,
+// the AWS error code and the body.
+func responseFields(operation string, try int, resp *http.Response, body []byte) Fields {
+	fields := Fields{"operation": operation, "attempt": try, "status": resp.StatusCode}
+	for _, header := range requestIdHeaders {
+		if id := resp.Header.Get(header); id != "" {
+			fields["request_id"] = id
+			break
+		}
+	}
+
+	if resp.StatusCode >= 400 {
+		document := gawsError{}
+		if json.Unmarshal(body, &document) == nil && document.Type != "" {
+			fields["error_code"] = document.Type
+		}
+		fields["body"] = string(body)
+	}
+	return fields
+}
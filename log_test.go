@@ -0,0 +1,99 @@
+package gaws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type logEntry struct {
+	level   LogLevel
+	message string
+	fields  Fields
+}
+
+// memoryLogger keeps log entries in a slice.
+type memoryLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (m *memoryLogger) Log(level LogLevel, message string, fields Fields) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, logEntry{level, message, fields})
+}
+
+func (m *memoryLogger) atLevel(level LogLevel) []logEntry {
+	result := []logEntry{}
+	for _, entry := range m.entries {
+		if entry.level == level {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+func TestRequestLogging(t *testing.T) {
+	Convey("Given DefaultLogger keeps what is logged", t, func() {
+		logger := &memoryLogger{}
+		SetDefaultLogger(logger)
+		defer SetDefaultLogger(nil)
+
+		Convey("A throttled request logs every attempt, backoff and the final error", func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Amzn-RequestId", "request-1")
+				testAWSThrottle(w, r)
+			}))
+			defer ts.Close()
+			r := canonicalRequest()
+			r.URL = ts.URL
+			r.Headers["X-Amz-Target"] = "Test.Op"
+			r.Do()
+
+			So(len(logger.atLevel(LevelDebug)), ShouldEqual, MaxTries-1)
+			retries := logger.atLevel(LevelWarn)
+			So(len(retries), ShouldEqual, MaxTries-1)
+			So(retries[0].fields["backoff"], ShouldNotBeNil)
+			So(retries[1].fields["attempt"], ShouldEqual, 2)
+
+			failures := logger.atLevel(LevelError)
+			So(len(failures), ShouldEqual, 1)
+			So(failures[0].fields["operation"], ShouldEqual, "Test.Op")
+			So(failures[0].fields["status"], ShouldEqual, 400)
+			So(failures[0].fields["error_code"], ShouldEqual, "Throttling")
+			So(failures[0].fields["request_id"], ShouldEqual, "request-1")
+			So(failures[0].fields["body"], ShouldContainSubstring, "You have been throttled")
+			So(failures[0].fields["backoff"], ShouldBeNil)
+		})
+		Convey("A request that is not retried logs its error", func() {
+			ts := httptest.NewServer(http.HandlerFunc(testHTTP404))
+			defer ts.Close()
+			r := canonicalRequest()
+			r.URL = ts.URL
+			r.Do()
+
+			failures := logger.atLevel(LevelError)
+			So(len(failures), ShouldEqual, 1)
+			So(failures[0].fields["error_code"], ShouldEqual, "NotFound")
+			So(failures[0].fields["error"], ShouldEqual, notFoundError.Error())
+		})
+		Convey("A successful request logs at debug level only", func() {
+			ts := httptest.NewServer(http.HandlerFunc(testHTTP200))
+			defer ts.Close()
+			r := canonicalRequest()
+			r.URL = ts.URL
+			r.Do()
+
+			So(len(logger.entries), ShouldEqual, 2)
+			So(logger.entries[1].message, ShouldEqual, "aws request succeeded")
+			So(logger.entries[1].fields["body"], ShouldBeNil)
+		})
+	})
+	Convey("LogLevel has a name", t, func() {
+		So(LevelWarn.String(), ShouldEqual, "warn")
+	})
+}
@@ -0,0 +1,233 @@
+package gaws
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Labels are the dimensions of a measurement, such as the operation a request was for.
+type Labels map[string]string
+
+// Metrics receives measurements from gaws and its service packages. Implementations must be safe for concurrent use.
+type Metrics interface {
+	Add(name string, labels Labels, delta float64)     // Add adds delta to a counter.
+	Set(name string, labels Labels, value float64)     // Set sets a gauge.
+	Observe(name string, labels Labels, value float64) // Observe adds a value to a histogram.
+}
+
+// metricsValue holds a Metrics in an atomic.Value, which needs every value it stores to have the same type.
+type metricsValue struct {
+	metrics Metrics
+}
+
+var defaultMetrics atomic.Value
+
+// DefaultMetrics returns the Metrics that receives every measurement. It discards them until SetDefaultMetrics replaces it.
+func DefaultMetrics() Metrics {
+	if v, ok := defaultMetrics.Load().(metricsValue); ok {
+		return v.metrics
+	}
+	return NopMetrics{}
+}
+
+// SetDefaultMetrics replaces the Metrics that receives every measurement, for example with a Registry. nil discards them.
+// It is safe to call while requests are being made.
+func SetDefaultMetrics(metrics Metrics) {
+	if metrics == nil {
+		metrics = NopMetrics{}
+	}
+	defaultMetrics.Store(metricsValue{metrics: metrics})
+}
+
+// NopMetrics discards every measurement.
+type NopMetrics struct{}
+
+func (NopMetrics) Add(name string, labels Labels, delta float64)     {}
+func (NopMetrics) Set(name string, labels Labels, value float64)     {}
+func (NopMetrics) Observe(name string, labels Labels, value float64) {}
+
+// Histogram buckets used by a Registry for metrics that have none in Registry.Buckets.
+var (
+	DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}               // For metrics named *_seconds.
+	SizeBuckets     = []float64{1, 4, 16, 64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304} // For every other histogram.
+)
+
+type metricKind int
+
+const (
+	counterKind metricKind = iota
+	gaugeKind
+	histogramKind
+)
+
+// series is one metric with one set of labels.
+type series struct {
+	labels Labels
+	value  float64   // The counter or gauge value.
+	bounds []float64 // The upper bounds of the histogram buckets.
+	counts []uint64  // How many observations fell in each bucket, not cumulative.
+	sum    float64
+	count  uint64
+}
+
+// family is every series of one metric.
+type family struct {
+	kind   metricKind
+	series map[string]*series
+}
+
+// Registry is a Metrics that keeps every measurement in memory so it can be exported with WritePrometheus or Expvar.
+// A metric keeps the kind it was first used as; measurements of another kind with the same name are ignored.
+type Registry struct {
+	Buckets map[string][]float64 // Histogram bucket upper bounds by metric name. Set it before the first measurement.
+
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{Buckets: map[string][]float64{}}
+}
+
+// labelKey is a canonical string for labels, used to find their series.
+func labelKey(labels Labels) string {
+	parts := make([]string, 0, len(labels))
+	for k, v := range labels {
+		parts = append(parts, k+"\xff"+v)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "\xfe")
+}
+
+// series returns the series for name and labels, creating it if needed. It returns nil if name is another kind of metric.
+// Callers hold r.mu.
+func (r *Registry) series(name string, kind metricKind, labels Labels) *series {
+	if r.families == nil {
+		r.families = map[string]*family{}
+	}
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{kind: kind, series: map[string]*series{}}
+		r.families[name] = f
+	}
+	if f.kind != kind {
+		return nil
+	}
+
+	key := labelKey(labels)
+	s, ok := f.series[key]
+	if !ok {
+		copied := Labels{}
+		for k, v := range labels {
+			copied[k] = v
+		}
+		s = &series{labels: copied}
+		if kind == histogramKind {
+			s.bounds = r.bucketsFor(name)
+			s.counts = make([]uint64, len(s.bounds))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (r *Registry) bucketsFor(name string) []float64 {
+	if buckets, ok := r.Buckets[name]; ok {
+		return buckets
+	}
+	if strings.HasSuffix(name, "_seconds") {
+		return DurationBuckets
+	}
+	return SizeBuckets
+}
+
+// Add adds delta to a counter.
+func (r *Registry) Add(name string, labels Labels, delta float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s := r.series(name, counterKind, labels); s != nil {
+		s.value += delta
+	}
+}
+
+// Set sets a gauge.
+func (r *Registry) Set(name string, labels Labels, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s := r.series(name, gaugeKind, labels); s != nil {
+		s.value = value
+	}
+}
+
+// Observe adds value to a histogram.
+func (r *Registry) Observe(name string, labels Labels, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.series(name, histogramKind, labels)
+	if s == nil {
+		return
+	}
+	s.sum += value
+	s.count++
+	for i, bound := range s.bounds {
+		if value <= bound {
+			s.counts[i]++
+			break
+		}
+	}
+}
+
+// Value returns the value of a counter or gauge, or the number of observations in a histogram. It is 0 if there is none.
+func (r *Registry) Value(name string, labels Labels) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.families[name]
+	if !ok {
+		return 0
+	}
+	s, ok := f.series[labelKey(labels)]
+	if !ok {
+		return 0
+	}
+	if f.kind == histogramKind {
+		return float64(s.count)
+	}
+	return s.value
+}
+
+// Sum returns the total of Value over every series of name whose labels include all of labels, such as every shard of a stream.
+func (r *Registry) Sum(name string, labels Labels) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.families[name]
+	if !ok {
+		return 0
+	}
+	total := 0.0
+	for _, s := range f.series {
+		if !hasLabels(s.labels, labels) {
+			continue
+		}
+		if f.kind == histogramKind {
+			total += float64(s.count)
+		} else {
+			total += s.value
+		}
+	}
+	return total
+}
+
+// hasLabels reports whether labels includes every label in subset.
+func hasLabels(labels Labels, subset Labels) bool {
+	for k, v := range subset {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package gaws
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegistry(t *testing.T) {
+	Convey("Given a registry with a counter, a gauge and a histogram", t, func() {
+		r := NewRegistry()
+		r.Buckets["batch_size"] = []float64{1, 10}
+		r.Add("requests_total", Labels{"operation": "Put"}, 1)
+		r.Add("requests_total", Labels{"operation": "Put"}, 2)
+		r.Add("requests_total", Labels{"operation": "Get"}, 1)
+		r.Set("age_milliseconds", nil, 5)
+		r.Set("age_milliseconds", nil, 7)
+		r.Observe("batch_size", Labels{"stream": "foo"}, 1)
+		r.Observe("batch_size", Labels{"stream": "foo"}, 5)
+		r.Observe("batch_size", Labels{"stream": "foo"}, 50)
+
+		Convey("Counters add up and gauges keep the last value", func() {
+			So(r.Value("requests_total", Labels{"operation": "Put"}), ShouldEqual, 3)
+			So(r.Value("age_milliseconds", nil), ShouldEqual, 7)
+			So(r.Value("batch_size", Labels{"stream": "foo"}), ShouldEqual, 3)
+		})
+		Convey("Sum adds up every series with the given labels", func() {
+			So(r.Sum("requests_total", nil), ShouldEqual, 4)
+			So(r.Sum("requests_total", Labels{"operation": "Get"}), ShouldEqual, 1)
+			So(r.Sum("requests_total", Labels{"operation": "List"}), ShouldEqual, 0)
+		})
+		Convey("A measurement of the wrong kind is ignored", func() {
+			r.Set("requests_total", Labels{"operation": "Put"}, 100)
+			So(r.Value("requests_total", Labels{"operation": "Put"}), ShouldEqual, 3)
+		})
+		Convey("WritePrometheus writes the text format", func() {
+			b := &bytes.Buffer{}
+			So(r.WritePrometheus(b), ShouldBeNil)
+			So(b.String(), ShouldEqual, strings.Join([]string{
+				"# TYPE age_milliseconds gauge",
+				"age_milliseconds 7",
+				"# TYPE batch_size histogram",
+				`batch_size_bucket{stream="foo",le="1"} 1`,
+				`batch_size_bucket{stream="foo",le="10"} 2`,
+				`batch_size_bucket{stream="foo",le="+Inf"} 3`,
+				`batch_size_sum{stream="foo"} 56`,
+				`batch_size_count{stream="foo"} 3`,
+				"# TYPE requests_total counter",
+				`requests_total{operation="Get"} 1`,
+				`requests_total{operation="Put"} 3`,
+				"",
+			}, "\n"))
+		})
+		Convey("Label values are escaped", func() {
+			So(formatLabels(Labels{"a": "say \"hi\"\n"}, "", ""), ShouldEqual, `{a="say \"hi\"\n"}`)
+		})
+		Convey("Expvar reports the metrics as JSON", func() {
+			values := map[string]map[string]json.RawMessage{}
+			So(json.Unmarshal([]byte(r.Expvar().String()), &values), ShouldBeNil)
+			So(string(values["requests_total"][`{operation="Put"}`]), ShouldEqual, "3")
+
+			histogram := expvarHistogram{}
+			json.Unmarshal(values["batch_size"][`{stream="foo"}`], &histogram)
+			So(histogram.Count, ShouldEqual, 3)
+			So(histogram.Buckets["10"], ShouldEqual, 2)
+		})
+	})
+}
+
+func TestRequestMetrics(t *testing.T) {
+	Convey("Given DefaultMetrics is a registry", t, func() {
+		registry := NewRegistry()
+		SetDefaultMetrics(registry)
+		defer SetDefaultMetrics(nil)
+
+		Convey("A successful request reports one attempt and its bytes", func() {
+			ts := httptest.NewServer(http.HandlerFunc(testHTTP200))
+			defer ts.Close()
+			r := canonicalRequest()
+			r.URL = ts.URL
+			r.Headers["X-Amz-Target"] = "Test.Op"
+			r.Body = []byte("hello")
+			r.Do()
+
+			So(registry.Value("gaws_request_attempts_total", Labels{"operation": "Test.Op", "attempt": "1", "status": "200"}), ShouldEqual, 1)
+			So(registry.Value("gaws_request_duration_seconds", Labels{"operation": "Test.Op", "status": "200"}), ShouldEqual, 1)
+			So(registry.Value("gaws_request_sent_bytes_total", Labels{"operation": "Test.Op"}), ShouldEqual, 5)
+			So(registry.Value("gaws_request_received_bytes_total", Labels{"operation": "Test.Op"}), ShouldEqual, 2)
+		})
+		Convey("A throttled request reports its retries", func() {
+			ts := httptest.NewServer(http.HandlerFunc(testAWSThrottle))
+			defer ts.Close()
+			r := canonicalRequest()
+			r.URL = ts.URL
+			r.Do()
+
+			So(registry.Value("gaws_request_retries_total", Labels{"operation": "GET", "status": "400"}), ShouldEqual, MaxTries-1)
+			So(registry.Value("gaws_request_exceeded_retries_total", Labels{"operation": "GET"}), ShouldEqual, 1)
+		})
+	})
+}